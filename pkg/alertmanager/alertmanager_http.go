@@ -7,7 +7,9 @@ package alertmanager
 
 import (
 	_ "embed" // Used to embed html template
+	"fmt"
 	"net/http"
+	"strconv"
 	"text/template"
 
 	"github.com/go-kit/log/level"
@@ -53,6 +55,22 @@ func (am *MultitenantAlertmanager) RingHandler(w http.ResponseWriter, req *http.
 	am.ring.ServeHTTP(w, req)
 }
 
+// ReadOnlyHandler shows whether the instance is read-only and, on POST, sets it from the
+// read_only parameter of the request.
+func (am *MultitenantAlertmanager) ReadOnlyHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		readOnly, err := strconv.ParseBool(req.FormValue("read_only"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid read_only parameter: %s", err), http.StatusBadRequest)
+			return
+		}
+		am.SetReadOnly(readOnly)
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "read_only: %t\n", am.ReadOnly())
+}
+
 // GetStatusHandler returns the status handler for this multi-tenant
 // alertmanager.
 func (am *MultitenantAlertmanager) GetStatusHandler() StatusHandler {
@@ -8,6 +8,7 @@ package alertmanager
 import (
 	"flag"
 	"fmt"
+	"math"
 	"os"
 	"time"
 
@@ -55,12 +56,15 @@ type RingConfig struct {
 	ReplicationFactor    int           `yaml:"replication_factor" category:"advanced"`
 	ZoneAwarenessEnabled bool          `yaml:"zone_awareness_enabled" category:"advanced"`
 
+	AutoForgetUnhealthyPeriods int `yaml:"auto_forget_unhealthy_periods" category:"advanced"`
+
 	// Instance details
 	InstanceID             string   `yaml:"instance_id" doc:"default=<hostname>" category:"advanced"`
 	InstanceInterfaceNames []string `yaml:"instance_interface_names" category:"advanced" doc:"default=[<private network interfaces>]"`
 	InstancePort           int      `yaml:"instance_port" category:"advanced"`
 	InstanceAddr           string   `yaml:"instance_addr" category:"advanced"`
 	InstanceZone           string   `yaml:"instance_availability_zone" category:"advanced"`
+	InstanceTokensWeight   float64  `yaml:"instance_tokens_weight" category:"advanced"`
 
 	// Injected internally
 	ListenPort      int           `yaml:"-"`
@@ -88,6 +92,7 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	f.DurationVar(&cfg.HeartbeatTimeout, rfprefix+"heartbeat-timeout", time.Minute, "The heartbeat timeout after which alertmanagers are considered unhealthy within the ring. 0 = never (timeout disabled).")
 	f.IntVar(&cfg.ReplicationFactor, rfprefix+"replication-factor", 3, "The replication factor to use when sharding the alertmanager.")
 	f.BoolVar(&cfg.ZoneAwarenessEnabled, rfprefix+"zone-awareness-enabled", false, "True to enable zone-awareness and replicate alerts across different availability zones.")
+	f.IntVar(&cfg.AutoForgetUnhealthyPeriods, rfprefix+"auto-forget-unhealthy-periods", ringAutoForgetUnhealthyPeriods, "Number of consecutive heartbeat timeout periods after which an unhealthy alertmanager is automatically removed from the ring. 0 = disabled.")
 
 	// Instance flags
 	cfg.InstanceInterfaceNames = netutil.PrivateNetworkInterfacesWithFallback([]string{"eth0", "en0"}, logger)
@@ -96,6 +101,7 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	f.IntVar(&cfg.InstancePort, rfprefix+"instance-port", 0, "Port to advertise in the ring (defaults to -server.grpc-listen-port).")
 	f.StringVar(&cfg.InstanceID, rfprefix+"instance-id", hostname, "Instance ID to register in the ring.")
 	f.StringVar(&cfg.InstanceZone, rfprefix+"instance-availability-zone", "", "The availability zone where this instance is running. Required if zone-awareness is enabled.")
+	f.Float64Var(&cfg.InstanceTokensWeight, rfprefix+"instance-tokens-weight", 1, "Multiplier applied to the number of tokens this instance registers in the ring. An instance with a weight of 2 owns roughly twice as many tenants as an instance with a weight of 1. Must be greater than 0.")
 
 	cfg.RingCheckPeriod = 5 * time.Second
 }
@@ -116,10 +122,29 @@ func (cfg *RingConfig) ToLifecyclerConfig(logger log.Logger) (ring.BasicLifecycl
 		HeartbeatPeriod:     cfg.HeartbeatPeriod,
 		TokensObservePeriod: 0,
 		Zone:                cfg.InstanceZone,
-		NumTokens:           RingNumTokens,
+		NumTokens:           cfg.NumTokens(),
 	}, nil
 }
 
+// AutoForgetPeriod returns how long an instance must have not heartbeated to be
+// automatically removed from the ring. 0 means instances are never auto-forgotten.
+func (cfg *RingConfig) AutoForgetPeriod() time.Duration {
+	if cfg.HeartbeatTimeout <= 0 || cfg.AutoForgetUnhealthyPeriods <= 0 {
+		return 0
+	}
+	return cfg.HeartbeatTimeout * time.Duration(cfg.AutoForgetUnhealthyPeriods)
+}
+
+// NumTokens returns the number of tokens this instance registers in the ring, which
+// is RingNumTokens scaled by the configured instance tokens weight. It's at least 1.
+func (cfg *RingConfig) NumTokens() int {
+	numTokens := int(math.Round(RingNumTokens * cfg.InstanceTokensWeight))
+	if numTokens < 1 {
+		return 1
+	}
+	return numTokens
+}
+
 func (cfg *RingConfig) ToRingConfig() ring.Config {
 	rc := ring.Config{}
 	flagext.DefaultValues(&rc)
@@ -6,28 +6,170 @@
 package alertmanager
 
 import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/ring"
 )
 
-func (am *MultitenantAlertmanager) OnRingInstanceRegister(_ *ring.BasicLifecycler, ringDesc ring.Desc, instanceExists bool, instanceID string, instanceDesc ring.InstanceDesc) (ring.InstanceState, ring.Tokens) {
+// OnRingInstanceRegister is called by the lifecycler within the CAS registering the instance.
+// The ring descriptor is received by value, but it shares the instances map with the one
+// the lifecycler then stores in the ring, so the instances removed from ringDesc.Ingesters,
+// either taken over or forgotten, are removed from the stored ring too. The delegate API
+// offers no other way to update the ring on registration: if dskit ever copies the map
+// before calling the delegate, these removals are lost, which is covered by
+// TestMultitenantAlertmanager_OnRingInstanceRegisterShouldRemoveInstancesFromTheStoredRing.
+func (am *MultitenantAlertmanager) OnRingInstanceRegister(lifecycler *ring.BasicLifecycler, ringDesc ring.Desc, instanceExists bool, instanceID string, instanceDesc ring.InstanceDesc) (ring.InstanceState, ring.Tokens) {
+	now := time.Now()
+
 	// When we initialize the alertmanager instance in the ring we want to start from
 	// a clean situation, so whatever is the state we set it JOINING, while we keep existing
 	// tokens (if any).
 	var tokens []uint32
 	if instanceExists {
 		tokens = instanceDesc.GetTokens()
+	} else if lifecycler != nil {
+		tokens = am.takeOverReplacedInstance(&ringDesc, instanceID, lifecycler.GetInstanceAddr(), now)
 	}
 
-	_, takenTokens := ringDesc.TokensFor(instanceID)
-	newTokens := ring.GenerateTokens(RingNumTokens-len(tokens), takenTokens)
+	// Forget long-dead instances before looking up the taken tokens, so that their
+	// tokens can be reused.
+	am.forgetUnhealthyInstances(&ringDesc, instanceID, now)
 
-	// Tokens sorting will be enforced by the parent caller.
-	tokens = append(tokens, newTokens...)
+	numTokens := am.cfg.ShardingRing.NumTokens()
+	if len(tokens) > numTokens {
+		// The instance tokens weight has been lowered since the tokens were registered, so
+		// only the lowest ones are kept. The tokens are copied not to sort the ones of the
+		// ring descriptor in place.
+		tokens = append([]uint32(nil), tokens...)
+		sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+		tokens = tokens[:numTokens]
+	} else {
+		// The taken tokens include the ones of the instances in any state, LEAVING included, so
+		// that the new tokens don't overlap with the ones of instances about to leave the ring.
+		_, takenTokens := ringDesc.TokensFor(instanceID)
+		newTokens := ring.GenerateTokens(numTokens-len(tokens), takenTokens)
+
+		// Tokens sorting will be enforced by the parent caller.
+		tokens = append(tokens, newTokens...)
+	}
+
+	// The instance registers as JOINING, so the read-only state of a previous registration
+	// isn't in the ring anymore. It's published again by the heartbeats once ACTIVE.
+	am.readOnlyInRing.Store(false)
 
 	return ring.JOINING, tokens
 }
 
 func (am *MultitenantAlertmanager) OnRingInstanceTokens(_ *ring.BasicLifecycler, _ ring.Tokens) {}
 func (am *MultitenantAlertmanager) OnRingInstanceStopping(_ *ring.BasicLifecycler)              {}
-func (am *MultitenantAlertmanager) OnRingInstanceHeartbeat(_ *ring.BasicLifecycler, _ *ring.Desc, _ *ring.InstanceDesc) {
+
+// OnRingInstanceHeartbeat records that the ring update in progress is a heartbeat, so that
+// its timestamp is tracked in the last heartbeat metric once it's stored. The metric is
+// only updated on successful heartbeats, so that it goes stale when heartbeats stop. Since
+// it's updated once per heartbeat period and read once per scrape interval, alerts on its
+// age should use a threshold of at least the heartbeat period plus the scrape interval.
+//
+// The heartbeat also publishes the read-only state of the instance in the ring: a read-only
+// ACTIVE instance is set LEAVING, so that the ring lookups skip it, and set back ACTIVE
+// once it's no longer read-only. Only the LEAVING state set by the heartbeat is cleared.
+func (am *MultitenantAlertmanager) OnRingInstanceHeartbeat(_ *ring.BasicLifecycler, _ *ring.Desc, instanceDesc *ring.InstanceDesc) {
+	am.ringHeartbeatPending.Store(true)
+
+	readOnly, inRing := am.readOnly.Load(), am.readOnlyInRing.Load()
+	switch {
+	case readOnly && instanceDesc.State == ring.ACTIVE:
+		level.Info(am.logger).Log("msg", "publishing the read-only state of the instance in the ring", "state", ring.LEAVING)
+		instanceDesc.State = ring.LEAVING
+		am.readOnlyInRing.Store(true)
+	case !readOnly && inRing && instanceDesc.State == ring.LEAVING:
+		level.Info(am.logger).Log("msg", "clearing the read-only state of the instance in the ring", "state", ring.ACTIVE)
+		instanceDesc.State = ring.ACTIVE
+	case !readOnly && inRing && instanceDesc.State == ring.ACTIVE:
+		// The previous heartbeat stored the ACTIVE state.
+		am.readOnlyInRing.Store(false)
+	}
+}
+
+// heartbeatTrackingClient wraps the KV client of the ring lifecycler, to update the last
+// heartbeat metric with the timestamp of the instance stored by a successful heartbeat.
+type heartbeatTrackingClient struct {
+	kv.Client
+	am         *MultitenantAlertmanager
+	instanceID string
+}
+
+func (c *heartbeatTrackingClient) CAS(ctx context.Context, key string, f func(in interface{}) (out interface{}, retry bool, err error)) error {
+	c.am.ringHeartbeatPending.Store(false)
+
+	var stored *ring.Desc
+	err := c.Client.CAS(ctx, key, func(in interface{}) (interface{}, bool, error) {
+		out, retry, err := f(in)
+		stored, _ = out.(*ring.Desc)
+		return out, retry, err
+	})
+	if err != nil || stored == nil || !c.am.ringHeartbeatPending.Swap(false) {
+		return err
+	}
+
+	if instance, ok := stored.Ingesters[c.instanceID]; ok {
+		c.am.ringLastHeartbeat.Set(float64(instance.Timestamp))
+	}
+	return nil
+}
+
+// takeOverReplacedInstance returns the tokens of the instance the registering one replaces,
+// and removes it from the ring. An instance registering under a new ID with the address
+// of unhealthy instances, for example after a restart changing its ID, replaces the one
+// registered for the longest time, so that the tokens stay stable across restarts rather
+// than being generated again. Healthy instances are never replaced. The removal relies on
+// ringDesc sharing its instances map with the ring stored by the lifecycler, as described in
+// OnRingInstanceRegister.
+func (am *MultitenantAlertmanager) takeOverReplacedInstance(ringDesc *ring.Desc, instanceID, instanceAddr string, now time.Time) []uint32 {
+	replacedID := ""
+	var replaced ring.InstanceDesc
+	for id, instance := range ringDesc.Ingesters {
+		if id == instanceID || instance.Addr != instanceAddr || instance.IsHeartbeatHealthy(am.cfg.ShardingRing.HeartbeatTimeout, now) {
+			continue
+		}
+
+		// Break the ties by ID, for the choice not to depend on the map ordering.
+		if replacedID == "" || instance.RegisteredTimestamp < replaced.RegisteredTimestamp ||
+			(instance.RegisteredTimestamp == replaced.RegisteredTimestamp && id < replacedID) {
+			replacedID, replaced = id, instance
+		}
+	}
+	if replacedID == "" {
+		return nil
+	}
+
+	level.Info(am.logger).Log("msg", "taking over the tokens of the unhealthy instance with the same address", "replaced_instance", replacedID, "addr", instanceAddr, "tokens", len(replaced.Tokens), "registered_at", replaced.GetRegisteredAt().String())
+	ringDesc.RemoveIngester(replacedID)
+	return replaced.Tokens
+}
+
+// forgetUnhealthyInstances removes from the ring the instances whose last heartbeat is older
+// than the auto-forget period. Instances still healthy according to the heartbeat timeout are
+// never removed. The removal relies on ringDesc sharing its instances map with the ring
+// stored by the lifecycler, as described in OnRingInstanceRegister.
+func (am *MultitenantAlertmanager) forgetUnhealthyInstances(ringDesc *ring.Desc, instanceID string, now time.Time) {
+	forgetPeriod := am.cfg.ShardingRing.AutoForgetPeriod()
+	if forgetPeriod <= 0 {
+		return
+	}
+
+	for id, instance := range ringDesc.Ingesters {
+		if id == instanceID || instance.IsHeartbeatHealthy(am.cfg.ShardingRing.HeartbeatTimeout, now) {
+			continue
+		}
+
+		lastHeartbeat := time.Unix(instance.GetTimestamp(), 0)
+		if now.Sub(lastHeartbeat) > forgetPeriod {
+			level.Warn(am.logger).Log("msg", "auto-forgetting instance from the ring because it is unhealthy for a long time", "instance", id, "last_heartbeat", lastHeartbeat.String(), "forget_period", forgetPeriod)
+			ringDesc.RemoveIngester(id)
+		}
+	}
 }
@@ -0,0 +1,273 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultitenantAlertmanager_OnRingInstanceRegisterShouldForgetLongDeadInstances(t *testing.T) {
+	const heartbeatTimeout = time.Minute
+
+	tests := map[string]struct {
+		autoForgetPeriods int
+		expectedInstances []string
+	}{
+		"auto-forget enabled": {
+			autoForgetPeriods: ringAutoForgetUnhealthyPeriods,
+			expectedInstances: []string{"healthy", "recently-unhealthy"},
+		},
+		"auto-forget disabled": {
+			autoForgetPeriods: 0,
+			expectedInstances: []string{"healthy", "recently-unhealthy", "long-dead"},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := mockAlertmanagerConfig(t)
+			cfg.ShardingRing.HeartbeatTimeout = heartbeatTimeout
+			cfg.ShardingRing.AutoForgetUnhealthyPeriods = testData.autoForgetPeriods
+			am := &MultitenantAlertmanager{cfg: cfg, logger: log.NewNopLogger()}
+
+			now := time.Now()
+			ringDesc := ring.NewDesc()
+			addInstance := func(id string, lastHeartbeat time.Time) {
+				instance := ringDesc.AddIngester(id, id, "", ring.GenerateTokens(RingNumTokens, ringDesc.GetTokens()), ring.ACTIVE, now)
+				instance.Timestamp = lastHeartbeat.Unix()
+				ringDesc.Ingesters[id] = instance
+			}
+			addInstance("healthy", now)
+			addInstance("recently-unhealthy", now.Add(-2*heartbeatTimeout))
+			addInstance("long-dead", now.Add(-(ringAutoForgetUnhealthyPeriods+1)*heartbeatTimeout))
+
+			state, tokens := am.OnRingInstanceRegister(nil, *ringDesc, false, cfg.ShardingRing.InstanceID, ring.InstanceDesc{})
+			assert.Equal(t, ring.JOINING, state)
+			assert.Len(t, tokens, RingNumTokens)
+
+			actualInstances := make([]string, 0, len(ringDesc.Ingesters))
+			for id := range ringDesc.Ingesters {
+				actualInstances = append(actualInstances, id)
+			}
+			require.ElementsMatch(t, testData.expectedInstances, actualInstances)
+		})
+	}
+}
+
+func TestMultitenantAlertmanager_OnRingInstanceRegisterShouldNotReuseTokensOfLeavingInstances(t *testing.T) {
+	cfg := mockAlertmanagerConfig(t)
+	am := &MultitenantAlertmanager{cfg: cfg, logger: log.NewNopLogger()}
+
+	// Seed the ring with instances in every state, LEAVING included.
+	now := time.Now()
+	ringDesc := ring.NewDesc()
+	takenTokens := map[uint32]string{}
+	for _, state := range []ring.InstanceState{ring.ACTIVE, ring.LEAVING, ring.PENDING, ring.JOINING} {
+		id := "instance-" + state.String()
+		tokens := ring.GenerateTokens(RingNumTokens, ringDesc.GetTokens())
+		ringDesc.AddIngester(id, id, "", tokens, state, now)
+		for _, token := range tokens {
+			takenTokens[token] = id
+		}
+	}
+
+	state, tokens := am.OnRingInstanceRegister(nil, *ringDesc, false, cfg.ShardingRing.InstanceID, ring.InstanceDesc{})
+	assert.Equal(t, ring.JOINING, state)
+	require.Len(t, tokens, RingNumTokens)
+
+	for _, token := range tokens {
+		owner, taken := takenTokens[token]
+		assert.False(t, taken, "token %d is already owned by %s", token, owner)
+	}
+}
+
+func TestMultitenantAlertmanager_OnRingInstanceRegisterShouldTakeOverTheTokensOfReplacedInstances(t *testing.T) {
+	const (
+		heartbeatTimeout = time.Minute
+		instanceAddr     = "1.2.3.4:9094"
+	)
+
+	cfg := mockAlertmanagerConfig(t)
+	cfg.ShardingRing.HeartbeatTimeout = heartbeatTimeout
+	cfg.ShardingRing.AutoForgetUnhealthyPeriods = 0
+	am := &MultitenantAlertmanager{cfg: cfg, logger: log.NewNopLogger()}
+
+	lifecycler, err := ring.NewBasicLifecycler(ring.BasicLifecyclerConfig{ID: "new", Addr: instanceAddr, NumTokens: RingNumTokens},
+		RingNameForServer, RingKey, nil, am, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	now := time.Now()
+	ringDesc := ring.NewDesc()
+	instanceTokens := map[string][]uint32{}
+	addInstance := func(id, addr string, registeredAt, lastHeartbeat time.Time) {
+		instance := ringDesc.AddIngester(id, addr, "", ring.GenerateTokens(RingNumTokens, ringDesc.GetTokens()), ring.ACTIVE, registeredAt)
+		instance.Timestamp = lastHeartbeat.Unix()
+		ringDesc.Ingesters[id] = instance
+		instanceTokens[id] = instance.Tokens
+	}
+	addInstance("healthy-same-addr", instanceAddr, now.Add(-4*time.Hour), now)
+	addInstance("unhealthy-other-addr", "5.6.7.8:9094", now.Add(-3*time.Hour), now.Add(-10*time.Minute))
+	addInstance("unhealthy-oldest", instanceAddr, now.Add(-2*time.Hour), now.Add(-10*time.Minute))
+	addInstance("unhealthy-newest", instanceAddr, now.Add(-time.Hour), now.Add(-5*time.Minute))
+
+	// The instance takes over the tokens of the unhealthy instance with the same address
+	// registered for the longest time, whatever the order of the instances in the ring.
+	state, tokens := am.OnRingInstanceRegister(lifecycler, *ringDesc, false, "new", ring.InstanceDesc{})
+	assert.Equal(t, ring.JOINING, state)
+	assert.ElementsMatch(t, instanceTokens["unhealthy-oldest"], tokens)
+
+	actualInstances := make([]string, 0, len(ringDesc.Ingesters))
+	for id := range ringDesc.Ingesters {
+		actualInstances = append(actualInstances, id)
+	}
+	assert.ElementsMatch(t, []string{"healthy-same-addr", "unhealthy-other-addr", "unhealthy-newest"}, actualInstances)
+
+	// The instance registering again under the same ID later on keeps its tokens.
+	instance := ringDesc.AddIngester("new", instanceAddr, "", tokens, ring.ACTIVE, now)
+	_, tokensAfterRestart := am.OnRingInstanceRegister(lifecycler, *ringDesc, true, "new", instance)
+	assert.ElementsMatch(t, tokens, tokensAfterRestart)
+	assert.Contains(t, ringDesc.Ingesters, "unhealthy-newest")
+}
+
+func TestMultitenantAlertmanager_OnRingInstanceRegisterShouldApplyTheTokensWeight(t *testing.T) {
+	cfg := mockAlertmanagerConfig(t)
+	cfg.ShardingRing.InstanceTokensWeight = 2
+	am := &MultitenantAlertmanager{cfg: cfg, logger: log.NewNopLogger()}
+
+	ringDesc := ring.NewDesc()
+	_, tokens := am.OnRingInstanceRegister(nil, *ringDesc, false, "instance", ring.InstanceDesc{})
+	require.Len(t, tokens, 2*RingNumTokens)
+	instance := ringDesc.AddIngester("instance", "instance", "", tokens, ring.ACTIVE, time.Now())
+	registered := append([]uint32(nil), instance.Tokens...)
+
+	// The weight goes up on restart: the instance keeps its tokens and gets new ones.
+	cfg.ShardingRing.InstanceTokensWeight = 3
+	_, tokensAfterRestart := am.OnRingInstanceRegister(nil, *ringDesc, true, "instance", instance)
+	require.Len(t, tokensAfterRestart, 3*RingNumTokens)
+	assert.Subset(t, tokensAfterRestart, registered)
+
+	// The weight goes down on restart: the instance only keeps its lowest tokens.
+	cfg.ShardingRing.InstanceTokensWeight = 0.5
+	_, tokensAfterRestart = am.OnRingInstanceRegister(nil, *ringDesc, true, "instance", instance)
+	require.Len(t, tokensAfterRestart, RingNumTokens/2)
+	assert.Equal(t, ring.Tokens(registered[:RingNumTokens/2]), tokensAfterRestart)
+
+	// The tokens of the ring descriptor are left untouched.
+	assert.Equal(t, registered, ringDesc.Ingesters["instance"].Tokens)
+}
+
+func TestMultitenantAlertmanager_OnRingInstanceRegisterShouldRemoveInstancesFromTheStoredRing(t *testing.T) {
+	const (
+		heartbeatTimeout = time.Minute
+		instanceAddr     = "1.2.3.4:9094"
+	)
+	ctx := context.Background()
+
+	cfg := mockAlertmanagerConfig(t)
+	cfg.ShardingRing.HeartbeatTimeout = heartbeatTimeout
+	cfg.ShardingRing.AutoForgetUnhealthyPeriods = ringAutoForgetUnhealthyPeriods
+	am := &MultitenantAlertmanager{cfg: cfg, logger: log.NewNopLogger()}
+
+	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	now := time.Now()
+	require.NoError(t, ringStore.CAS(ctx, RingKey, func(in interface{}) (interface{}, bool, error) {
+		ringDesc := ring.GetOrCreateRingDesc(in)
+		addInstance := func(id, addr string, lastHeartbeat time.Time) {
+			instance := ringDesc.AddIngester(id, addr, "", ring.GenerateTokens(RingNumTokens, ringDesc.GetTokens()), ring.ACTIVE, now.Add(-time.Hour))
+			instance.Timestamp = lastHeartbeat.Unix()
+			ringDesc.Ingesters[id] = instance
+		}
+		addInstance("healthy", "5.6.7.8:9094", now)
+		addInstance("replaced", instanceAddr, now.Add(-5*time.Minute))
+		addInstance("long-dead", "9.9.9.9:9094", now.Add(-(ringAutoForgetUnhealthyPeriods+1)*heartbeatTimeout))
+		return ringDesc, true, nil
+	}))
+
+	// The instances are removed by the delegate from the descriptor it receives by value,
+	// which must be persisted by the registration of the lifecycler.
+	lifecycler, err := ring.NewBasicLifecycler(ring.BasicLifecyclerConfig{ID: "new", Addr: instanceAddr, NumTokens: RingNumTokens, HeartbeatPeriod: time.Minute},
+		RingNameForServer, RingKey, ringStore, am, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, lifecycler))
+	t.Cleanup(func() { require.NoError(t, services.StopAndAwaitTerminated(ctx, lifecycler)) })
+
+	stored, err := ringStore.Get(ctx, RingKey)
+	require.NoError(t, err)
+	actualInstances := []string{}
+	for id := range stored.(*ring.Desc).Ingesters {
+		actualInstances = append(actualInstances, id)
+	}
+	assert.ElementsMatch(t, []string{"healthy", "new"}, actualInstances)
+}
+
+func TestMultitenantAlertmanager_OnRingInstanceHeartbeatShouldTrackLastHeartbeat(t *testing.T) {
+	const instanceID = "instance-1"
+
+	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	am := &MultitenantAlertmanager{
+		cfg:               mockAlertmanagerConfig(t),
+		logger:            log.NewNopLogger(),
+		ringLastHeartbeat: prometheus.NewGauge(prometheus.GaugeOpts{Name: "test"}),
+	}
+	store := &heartbeatTrackingClient{Client: ringStore, am: am, instanceID: instanceID}
+
+	// heartbeat stores the instance with the given timestamp the way the lifecycler does,
+	// calling the delegate from the CAS callback.
+	heartbeat := func(timestamp time.Time, casErr error) error {
+		return store.CAS(context.Background(), RingKey, func(in interface{}) (interface{}, bool, error) {
+			desc := ring.GetOrCreateRingDesc(in)
+			instance := desc.Ingesters[instanceID]
+			am.OnRingInstanceHeartbeat(nil, desc, &instance)
+			if casErr != nil {
+				return nil, false, casErr
+			}
+
+			instance.Timestamp = timestamp.Unix()
+			desc.Ingesters[instanceID] = instance
+			return desc, true, nil
+		})
+	}
+
+	// The metric is not set before the first heartbeat.
+	assert.Equal(t, float64(0), testutil.ToFloat64(am.ringLastHeartbeat))
+
+	// The metric is the timestamp stored by the heartbeat, rather than the current time.
+	first := time.Unix(1000, 0)
+	require.NoError(t, heartbeat(first, nil))
+	assert.Equal(t, float64(first.Unix()), testutil.ToFloat64(am.ringLastHeartbeat))
+
+	// Failed heartbeats don't update the metric.
+	require.Error(t, heartbeat(first.Add(time.Minute), errors.New("CAS failed")))
+	assert.Equal(t, float64(first.Unix()), testutil.ToFloat64(am.ringLastHeartbeat))
+
+	// Ring updates other than heartbeats don't update the metric.
+	require.NoError(t, store.CAS(context.Background(), RingKey, func(in interface{}) (interface{}, bool, error) {
+		desc := ring.GetOrCreateRingDesc(in)
+		instance := desc.Ingesters[instanceID]
+		instance.State = ring.ACTIVE
+		instance.Timestamp = first.Add(2 * time.Minute).Unix()
+		desc.Ingesters[instanceID] = instance
+		return desc, true, nil
+	}))
+	assert.Equal(t, float64(first.Unix()), testutil.ToFloat64(am.ringLastHeartbeat))
+
+	second := first.Add(3 * time.Minute)
+	require.NoError(t, heartbeat(second, nil))
+	assert.Equal(t, float64(second.Unix()), testutil.ToFloat64(am.ringLastHeartbeat))
+}
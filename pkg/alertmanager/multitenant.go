@@ -7,18 +7,22 @@ package alertmanager
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/dskit/concurrency"
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/kv"
@@ -34,6 +38,7 @@ import (
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/httpgrpc/server"
 	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
 	"golang.org/x/time/rate"
 
 	"github.com/grafana/dskit/tenant"
@@ -50,8 +55,8 @@ const (
 	reasonInitial    = "initial"
 	reasonRingChange = "ring-change"
 
-	// ringAutoForgetUnhealthyPeriods is how many consecutive timeout periods an unhealthy instance
-	// in the ring will be automatically removed.
+	// ringAutoForgetUnhealthyPeriods is the default number of consecutive timeout periods after which
+	// an unhealthy instance in the ring will be automatically removed.
 	ringAutoForgetUnhealthyPeriods = 5
 )
 
@@ -60,6 +65,7 @@ var (
 	errInvalidExternalURL                  = errors.New("the configured external URL is invalid: should not end with /")
 	errShardingUnsupportedStorage          = errors.New("the configured alertmanager storage backend is not supported when sharding is enabled")
 	errZoneAwarenessEnabledWithoutZoneInfo = errors.New("the configured alertmanager has zone awareness enabled but zone is not set")
+	errInvalidInstanceTokensWeight         = errors.New("the configured alertmanager instance tokens weight must be greater than 0")
 	errNotUploadingFallback                = errors.New("not uploading fallback configuration")
 )
 
@@ -135,6 +141,9 @@ func (cfg *MultitenantAlertmanagerConfig) Validate(storageCfg alertstore.Config)
 	if cfg.ShardingRing.ZoneAwarenessEnabled && cfg.ShardingRing.InstanceZone == "" {
 		return errZoneAwarenessEnabledWithoutZoneInfo
 	}
+	if cfg.ShardingRing.InstanceTokensWeight <= 0 {
+		return errInvalidInstanceTokensWeight
+	}
 
 	return nil
 }
@@ -229,6 +238,7 @@ type MultitenantAlertmanager struct {
 	//     -> HandleRequest() (gRPC call) -> grpcServer() -> handlerForGRPCServer.ServeHTTP() -> serveRequest().
 	ringLifecycler *ring.BasicLifecycler
 	ring           *ring.Ring
+	ringStore      kv.Client
 	distributor    *Distributor
 	grpcServer     *server.Server
 
@@ -236,6 +246,9 @@ type MultitenantAlertmanager struct {
 	// accessed by a single goroutine at a time.
 	ringLastState ring.ReplicationSet
 
+	// Set while the ring lifecycler stores a heartbeat, see heartbeatTrackingClient.
+	ringHeartbeatPending atomic.Bool
+
 	// Subservices manager (ring, lifecycler)
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
@@ -247,6 +260,12 @@ type MultitenantAlertmanager struct {
 	// effect here.
 	fallbackConfig string
 
+	// When read-only, the instance keeps the tenants it's running but doesn't take
+	// ownership of new ones, for example during maintenance. The state is published in
+	// the ring by the heartbeat delegate, which sets readOnlyInRing when it does.
+	readOnly       atomic.Bool
+	readOnlyInRing atomic.Bool
+
 	alertmanagersMtx sync.Mutex
 	alertmanagers    map[string]*Alertmanager
 	// Stores the current set of configurations we're running in each tenant's Alertmanager.
@@ -263,6 +282,7 @@ type MultitenantAlertmanager struct {
 
 	registry          prometheus.Registerer
 	ringCheckErrors   prometheus.Counter
+	ringLastHeartbeat prometheus.Gauge
 	tenantsOwned      prometheus.Gauge
 	tenantsDiscovered prometheus.Gauge
 	syncTotal         *prometheus.CounterVec
@@ -317,6 +337,10 @@ func createMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, fallbackC
 			Name: "cortex_alertmanager_ring_check_errors_total",
 			Help: "Number of errors that have occurred when checking the ring for ownership.",
 		}),
+		ringLastHeartbeat: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_alertmanager_ring_last_heartbeat_timestamp_seconds",
+			Help: "Timestamp of the last heartbeat of the Alertmanager instance to the ring. It's only updated on actual heartbeats, so it goes stale when the instance stops heartbeating.",
+		}),
 		syncTotal: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
 			Name: "cortex_alertmanager_sync_configs_total",
 			Help: "Total number of times the alertmanager sync operation triggered.",
@@ -350,13 +374,17 @@ func createMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, fallbackC
 	// chained via "next delegate").
 	delegate := ring.BasicLifecyclerDelegate(am)
 	delegate = ring.NewLeaveOnStoppingDelegate(delegate, am.logger)
-	delegate = ring.NewAutoForgetDelegate(am.cfg.ShardingRing.HeartbeatTimeout*ringAutoForgetUnhealthyPeriods, delegate, am.logger)
+	if forgetPeriod := am.cfg.ShardingRing.AutoForgetPeriod(); forgetPeriod > 0 {
+		delegate = ring.NewAutoForgetDelegate(forgetPeriod, delegate, am.logger)
+	}
 
-	am.ringLifecycler, err = ring.NewBasicLifecycler(lifecyclerCfg, RingNameForServer, RingKey, ringStore, delegate, am.logger, prometheus.WrapRegistererWithPrefix("cortex_", am.registry))
+	lifecyclerStore := &heartbeatTrackingClient{Client: ringStore, am: am, instanceID: lifecyclerCfg.ID}
+	am.ringLifecycler, err = ring.NewBasicLifecycler(lifecyclerCfg, RingNameForServer, RingKey, lifecyclerStore, delegate, am.logger, prometheus.WrapRegistererWithPrefix("cortex_", am.registry))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to initialize Alertmanager's lifecycler")
 	}
 
+	am.ringStore = ringStore
 	am.ring, err = ring.NewWithStoreClientAndStrategy(am.cfg.ShardingRing.ToRingConfig(), RingNameForServer, RingKey, ringStore, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), prometheus.WrapRegistererWithPrefix("cortex_", am.registry), am.logger)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to initialize Alertmanager's ring")
@@ -687,6 +715,19 @@ func (am *MultitenantAlertmanager) loadAlertmanagerConfigs(ctx context.Context)
 }
 
 func (am *MultitenantAlertmanager) isUserOwned(userID string) bool {
+	// The ring excludes a read-only instance from the tenants' replicas, so it only keeps
+	// the tenants it's already running.
+	if am.readOnly.Load() {
+		am.alertmanagersMtx.Lock()
+		_, running := am.alertmanagers[userID]
+		am.alertmanagersMtx.Unlock()
+
+		if !running {
+			level.Debug(am.logger).Log("msg", "not taking ownership of the user because the instance is read-only", "user", userID)
+		}
+		return running
+	}
+
 	alertmanagers, err := am.ring.Get(shardByUser(userID), SyncRingOp, nil, nil, nil)
 	if err != nil {
 		am.ringCheckErrors.Inc()
@@ -697,6 +738,23 @@ func (am *MultitenantAlertmanager) isUserOwned(userID string) bool {
 	return alertmanagers.Includes(am.ringLifecycler.GetInstanceAddr())
 }
 
+// SetReadOnly sets whether the instance is read-only. A read-only instance stays in the
+// ring and keeps running the Alertmanager of the tenants it's running, but doesn't start
+// the ones of other tenants. The next heartbeat publishes the state in the ring, as the
+// LEAVING state, so that the other instances and the distributor skip the instance and
+// the tenants are assigned to the next replicas. The read-only state isn't persisted, so
+// it's cleared when the instance restarts.
+func (am *MultitenantAlertmanager) SetReadOnly(readOnly bool) {
+	if am.readOnly.Swap(readOnly) != readOnly {
+		level.Info(am.logger).Log("msg", "changed the read-only mode of the instance", "read_only", readOnly)
+	}
+}
+
+// ReadOnly returns whether the instance is read-only.
+func (am *MultitenantAlertmanager) ReadOnly() bool {
+	return am.readOnly.Load()
+}
+
 func (am *MultitenantAlertmanager) syncConfigs(cfgs map[string]alertspb.AlertConfigDesc) {
 	level.Debug(am.logger).Log("msg", "adding configurations", "num_configs", len(cfgs))
 	for user, cfg := range cfgs {
@@ -897,6 +955,162 @@ func (am *MultitenantAlertmanager) GetPositionForUser(userID string) int {
 	return position
 }
 
+// CheckReplication returns an error if the configured replication factor is greater than the
+// number of healthy instances in the ring, in which case tenants are replicated to fewer
+// instances than configured.
+func (am *MultitenantAlertmanager) CheckReplication() error {
+	set, err := am.ring.GetAllHealthy(RingOp)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the alertmanager ring")
+	}
+
+	if rf := am.cfg.ShardingRing.ReplicationFactor; rf > len(set.Instances) {
+		return fmt.Errorf("the configured replication factor %d is greater than the number of healthy alertmanager instances in the ring %d: each tenant is replicated to %d instances only", rf, len(set.Instances), len(set.Instances))
+	}
+
+	return nil
+}
+
+// WaitRingStable waits until all the instances of the ring are healthy and ACTIVE, for
+// example to block during a rollout until no instance is JOINING or LEAVING anymore. It
+// returns an error if the ring is not stable before the context is done. An empty ring
+// is not stable.
+func (am *MultitenantAlertmanager) WaitRingStable(ctx context.Context) error {
+	backoff := backoff.New(ctx, backoff.Config{
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: time.Second,
+	})
+
+	for backoff.Ongoing() {
+		// RingOp only selects the healthy ACTIVE instances.
+		set, err := am.ring.GetAllHealthy(RingOp)
+		if err == nil && len(set.Instances) > 0 && len(set.Instances) == am.ring.InstancesCount() {
+			return nil
+		}
+
+		backoff.Wait()
+	}
+
+	return errors.Wrap(backoff.Err(), "the alertmanager ring is not stable")
+}
+
+type ringDump struct {
+	Instances []ringInstanceDump `json:"instances"`
+}
+
+type ringInstanceDump struct {
+	ID            string    `json:"id"`
+	Address       string    `json:"address"`
+	Zone          string    `json:"zone,omitempty"`
+	State         string    `json:"state"`
+	Tokens        int       `json:"tokens"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	RegisteredAt  time.Time `json:"registered_at"`
+}
+
+// DumpRing returns a JSON snapshot of the ring as currently stored in the KV store, listing
+// the state and the number of tokens of each instance. It's meant for diagnostics only.
+func (am *MultitenantAlertmanager) DumpRing() ([]byte, error) {
+	if am.ringStore == nil {
+		return nil, errors.New("the alertmanager ring is not configured")
+	}
+
+	value, err := am.ringStore.Get(context.Background(), RingKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the alertmanager ring")
+	}
+
+	dump := ringDump{Instances: []ringInstanceDump{}}
+	for id, instance := range ring.GetOrCreateRingDesc(value).GetIngesters() {
+		dump.Instances = append(dump.Instances, ringInstanceDump{
+			ID:            id,
+			Address:       instance.GetAddr(),
+			Zone:          instance.GetZone(),
+			State:         instance.GetState().String(),
+			Tokens:        len(instance.GetTokens()),
+			LastHeartbeat: time.Unix(instance.GetTimestamp(), 0).UTC(),
+			RegisteredAt:  instance.GetRegisteredAt().UTC(),
+		})
+	}
+	sort.Slice(dump.Instances, func(i, j int) bool {
+		return dump.Instances[i].ID < dump.Instances[j].ID
+	})
+
+	return json.Marshal(dump)
+}
+
+// TokenStats describes the share of the ring owned by an alertmanager instance.
+type TokenStats struct {
+	// Tokens is the number of tokens of the instance.
+	Tokens int
+	// Ownership is the percentage of the ring token space owned by the instance.
+	Ownership float64
+	// MinRange and MaxRange are the sizes of the smallest and largest token ranges owned
+	// by the instance. A large difference between them hints at an unbalanced ring.
+	MinRange uint32
+	MaxRange uint32
+}
+
+// TokenDistribution returns the share of the ring owned by each instance, keyed by instance
+// ID, as currently stored in the KV store. Each token owns the range of the token space
+// following the previous token of the ring, which is where the tenants hashing in that range
+// are sharded to. The ownership of all the instances sums up to 100%.
+func (am *MultitenantAlertmanager) TokenDistribution() (map[string]TokenStats, error) {
+	if am.ringStore == nil {
+		return nil, errors.New("the alertmanager ring is not configured")
+	}
+
+	value, err := am.ringStore.Get(context.Background(), RingKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the alertmanager ring")
+	}
+
+	return tokenDistribution(ring.GetOrCreateRingDesc(value)), nil
+}
+
+func tokenDistribution(desc *ring.Desc) map[string]TokenStats {
+	owners := map[uint32]string{}
+	stats := make(map[string]TokenStats, len(desc.GetIngesters()))
+	for id, instance := range desc.GetIngesters() {
+		for _, token := range instance.GetTokens() {
+			owners[token] = id
+		}
+		stats[id] = TokenStats{Tokens: len(instance.GetTokens())}
+	}
+
+	tokens := desc.GetTokens()
+	for i, token := range tokens {
+		// The first token also owns the range wrapping around the end of the ring. The
+		// ranges are computed as uint64, so that a single token owns the whole ring.
+		var size uint64
+		if i == 0 {
+			size = uint64(token) + math.MaxUint32 + 1 - uint64(tokens[len(tokens)-1])
+		} else {
+			size = uint64(token - tokens[i-1])
+		}
+		rangeSize := uint32(math.Min(float64(size), math.MaxUint32))
+
+		id := owners[token]
+		s := stats[id]
+		if s.MinRange == 0 || rangeSize < s.MinRange {
+			s.MinRange = rangeSize
+		}
+		if rangeSize > s.MaxRange {
+			s.MaxRange = rangeSize
+		}
+		s.Ownership += float64(size) / (math.MaxUint32 + 1) * 100
+		stats[id] = s
+	}
+
+	return stats
+}
+
+// HashKey returns the ring token the key hashes to, as used to shard the tenants, with
+// the tenant ID as key, for example to find the instances a tenant is assigned to.
+func (am *MultitenantAlertmanager) HashKey(key string) uint32 {
+	return shardByUser(key)
+}
+
 // ServeHTTP serves the Alertmanager's web UI and API.
 func (am *MultitenantAlertmanager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if am.State() != services.Running {
@@ -177,6 +177,12 @@ func TestMultitenantAlertmanagerConfig_Validate(t *testing.T) {
 			},
 			expected: errZoneAwarenessEnabledWithoutZoneInfo,
 		},
+		"should fail if instance tokens weight is 0": {
+			setup: func(t *testing.T, cfg *MultitenantAlertmanagerConfig, storageCfg *alertstore.Config) {
+				cfg.ShardingRing.InstanceTokensWeight = 0
+			},
+			expected: errInvalidInstanceTokensWeight,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -1154,6 +1160,462 @@ func TestMultitenantAlertmanager_InitialSync(t *testing.T) {
 	}
 }
 
+func TestMultitenantAlertmanager_InitialSyncWithShardingShouldScaleTokensByWeight(t *testing.T) {
+	tc := map[string]struct {
+		weight            float64
+		expectedNumTokens int
+	}{
+		"default weight": {
+			weight:            1,
+			expectedNumTokens: RingNumTokens,
+		},
+		"double weight": {
+			weight:            2,
+			expectedNumTokens: 2 * RingNumTokens,
+		},
+		"half weight": {
+			weight:            0.5,
+			expectedNumTokens: RingNumTokens / 2,
+		},
+		"tiny weight registers at least 1 token": {
+			weight:            0.0001,
+			expectedNumTokens: 1,
+		},
+	}
+
+	for name, tt := range tc {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			amConfig := mockAlertmanagerConfig(t)
+			amConfig.ShardingRing.InstanceTokensWeight = tt.weight
+
+			ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+			t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+			am, err := createMultitenantAlertmanager(amConfig, nil, prepareInMemoryAlertStore(), ringStore, nil, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+			require.NoError(t, services.StartAndAwaitRunning(ctx, am))
+			defer services.StopAndAwaitTerminated(ctx, am) //nolint:errcheck
+
+			require.Equal(t, ring.ACTIVE.String(), am.ringLifecycler.GetState().String())
+			require.Equal(t, tt.expectedNumTokens, len(am.ringLifecycler.GetTokens()))
+		})
+	}
+}
+
+func TestMultitenantAlertmanager_DumpRing(t *testing.T) {
+	ctx := context.Background()
+	amConfig := mockAlertmanagerConfig(t)
+
+	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	registeredAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, ringStore.CAS(ctx, RingKey, func(in interface{}) (interface{}, bool, error) {
+		ringDesc := ring.GetOrCreateRingDesc(in)
+		ringDesc.AddIngester("alertmanager-1", "127.0.0.1", "zone-a", ring.Tokens{1, 2, 3}, ring.LEAVING, registeredAt)
+		return ringDesc, true, nil
+	}))
+
+	am, err := createMultitenantAlertmanager(amConfig, nil, prepareInMemoryAlertStore(), ringStore, nil, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	dump, err := am.DumpRing()
+	require.NoError(t, err)
+
+	actual := ringDump{}
+	require.NoError(t, json.Unmarshal(dump, &actual))
+	require.Len(t, actual.Instances, 1)
+
+	instance := actual.Instances[0]
+	assert.Equal(t, "alertmanager-1", instance.ID)
+	assert.Equal(t, "127.0.0.1", instance.Address)
+	assert.Equal(t, "zone-a", instance.Zone)
+	assert.Equal(t, ring.LEAVING.String(), instance.State)
+	assert.Equal(t, 3, instance.Tokens)
+	assert.True(t, registeredAt.Equal(instance.RegisteredAt))
+}
+
+func TestMultitenantAlertmanager_TokenDistribution(t *testing.T) {
+	ctx := context.Background()
+	amConfig := mockAlertmanagerConfig(t)
+
+	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	// alertmanager-1 owns the first quarter of the ring, alertmanager-2 the second one and,
+	// wrapping around, the last half.
+	const quarter = uint32(1 << 30)
+	require.NoError(t, ringStore.CAS(ctx, RingKey, func(in interface{}) (interface{}, bool, error) {
+		ringDesc := ring.GetOrCreateRingDesc(in)
+		ringDesc.AddIngester("alertmanager-1", "127.0.0.1", "", ring.Tokens{quarter}, ring.ACTIVE, time.Now())
+		ringDesc.AddIngester("alertmanager-2", "127.0.0.2", "", ring.Tokens{2 * quarter, 3 * quarter}, ring.ACTIVE, time.Now())
+		ringDesc.AddIngester("alertmanager-3", "127.0.0.3", "", nil, ring.JOINING, time.Now())
+		return ringDesc, true, nil
+	}))
+
+	am, err := createMultitenantAlertmanager(amConfig, nil, prepareInMemoryAlertStore(), ringStore, nil, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	stats, err := am.TokenDistribution()
+	require.NoError(t, err)
+	require.Len(t, stats, 3)
+
+	assert.Equal(t, 1, stats["alertmanager-1"].Tokens)
+	assert.InDelta(t, 50, stats["alertmanager-1"].Ownership, 0.001)
+	assert.Equal(t, 2*quarter, stats["alertmanager-1"].MinRange)
+	assert.Equal(t, 2*quarter, stats["alertmanager-1"].MaxRange)
+
+	assert.Equal(t, 2, stats["alertmanager-2"].Tokens)
+	assert.InDelta(t, 50, stats["alertmanager-2"].Ownership, 0.001)
+	assert.Equal(t, quarter, stats["alertmanager-2"].MinRange)
+	assert.Equal(t, quarter, stats["alertmanager-2"].MaxRange)
+
+	assert.Equal(t, TokenStats{}, stats["alertmanager-3"])
+
+	t.Run("random tokens", func(t *testing.T) {
+		desc := ring.NewDesc()
+		var taken []uint32
+		for i := 0; i < 5; i++ {
+			tokens := ring.GenerateTokens(RingNumTokens, taken)
+			taken = append(taken, tokens...)
+			desc.AddIngester(fmt.Sprintf("alertmanager-%d", i), fmt.Sprintf("127.0.0.%d", i), "", tokens, ring.ACTIVE, time.Now())
+		}
+
+		total := 0.0
+		for _, s := range tokenDistribution(desc) {
+			assert.Equal(t, RingNumTokens, s.Tokens)
+			assert.LessOrEqual(t, s.MinRange, s.MaxRange)
+			total += s.Ownership
+		}
+		assert.InDelta(t, 100, total, 0.001)
+	})
+}
+
+func TestMultitenantAlertmanager_HashKey(t *testing.T) {
+	am := &MultitenantAlertmanager{}
+
+	// FNV-1a 32-bit hash of "user-1".
+	assert.Equal(t, uint32(4115888500), am.HashKey("user-1"))
+	assert.Equal(t, shardByUser("user-2"), am.HashKey("user-2"))
+}
+
+func TestMultitenantAlertmanager_CheckReplication(t *testing.T) {
+	tests := map[string]struct {
+		replicationFactor int
+		healthyInstances  int
+		expectedErr       string
+	}{
+		"replication factor lower than the number of healthy instances": {
+			replicationFactor: 2,
+			healthyInstances:  3,
+		},
+		"replication factor equal to the number of healthy instances": {
+			replicationFactor: 3,
+			healthyInstances:  3,
+		},
+		"replication factor greater than the number of healthy instances": {
+			replicationFactor: 3,
+			healthyInstances:  2,
+			expectedErr:       "the configured replication factor 3 is greater than the number of healthy alertmanager instances in the ring 2",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := context.Background()
+			amConfig := mockAlertmanagerConfig(t)
+			amConfig.ShardingRing.ReplicationFactor = testData.replicationFactor
+
+			ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+			t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+			require.NoError(t, ringStore.CAS(ctx, RingKey, func(in interface{}) (interface{}, bool, error) {
+				ringDesc := ring.GetOrCreateRingDesc(in)
+				for i := 0; i < testData.healthyInstances; i++ {
+					id := fmt.Sprintf("alertmanager-%d", i)
+					ringDesc.AddIngester(id, fmt.Sprintf("127.0.0.%d", i+1), "", ring.GenerateTokens(RingNumTokens, ringDesc.GetTokens()), ring.ACTIVE, time.Now())
+				}
+
+				// An unhealthy instance is not counted.
+				ringDesc.AddIngester("unhealthy", "127.0.1.1", "", ring.GenerateTokens(RingNumTokens, ringDesc.GetTokens()), ring.LEAVING, time.Now())
+				return ringDesc, true, nil
+			}))
+
+			am, err := createMultitenantAlertmanager(amConfig, nil, prepareInMemoryAlertStore(), ringStore, nil, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+			require.NoError(t, services.StartAndAwaitRunning(ctx, am.ring))
+			t.Cleanup(func() { require.NoError(t, services.StopAndAwaitTerminated(ctx, am.ring)) })
+
+			err = am.CheckReplication()
+			if testData.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErr)
+			}
+		})
+	}
+}
+
+func TestMultitenantAlertmanager_WaitRingStable(t *testing.T) {
+	ctx := context.Background()
+	amConfig := mockAlertmanagerConfig(t)
+
+	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	require.NoError(t, ringStore.CAS(ctx, RingKey, func(in interface{}) (interface{}, bool, error) {
+		ringDesc := ring.GetOrCreateRingDesc(in)
+		ringDesc.AddIngester("alertmanager-1", "127.0.0.1", "", ring.GenerateTokens(RingNumTokens, ringDesc.GetTokens()), ring.ACTIVE, time.Now())
+		ringDesc.AddIngester("alertmanager-2", "127.0.0.2", "", ring.GenerateTokens(RingNumTokens, ringDesc.GetTokens()), ring.JOINING, time.Now())
+		return ringDesc, true, nil
+	}))
+
+	am, err := createMultitenantAlertmanager(amConfig, nil, prepareInMemoryAlertStore(), ringStore, nil, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, am.ring))
+	t.Cleanup(func() { require.NoError(t, services.StopAndAwaitTerminated(ctx, am.ring)) })
+
+	// The ring is not stable while an instance is JOINING.
+	shortCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	err = am.WaitRingStable(shortCtx)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The ring becomes stable once the instance is ACTIVE.
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		assert.NoError(t, ringStore.CAS(ctx, RingKey, func(in interface{}) (interface{}, bool, error) {
+			ringDesc := ring.GetOrCreateRingDesc(in)
+			instance := ringDesc.Ingesters["alertmanager-2"]
+			instance.State = ring.ACTIVE
+			instance.Timestamp = time.Now().Unix()
+			ringDesc.Ingesters["alertmanager-2"] = instance
+			return ringDesc, true, nil
+		}))
+	}()
+
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, am.WaitRingStable(waitCtx))
+}
+
+func TestMultitenantAlertmanager_RingMetrics(t *testing.T) {
+	ctx := context.Background()
+	amConfig := mockAlertmanagerConfig(t)
+
+	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	require.NoError(t, ringStore.CAS(ctx, RingKey, func(in interface{}) (interface{}, bool, error) {
+		ringDesc := ring.GetOrCreateRingDesc(in)
+		ringDesc.AddIngester("alertmanager-1", "127.0.0.1", "", ring.GenerateTokens(RingNumTokens, ringDesc.GetTokens()), ring.ACTIVE, time.Now())
+		ringDesc.AddIngester("alertmanager-2", "127.0.0.2", "", ring.GenerateTokens(RingNumTokens, ringDesc.GetTokens()), ring.ACTIVE, time.Now())
+		ringDesc.AddIngester("alertmanager-3", "127.0.0.3", "", ring.GenerateTokens(64, ringDesc.GetTokens()), ring.LEAVING, time.Now())
+		return ringDesc, true, nil
+	}))
+
+	reg := prometheus.NewPedanticRegistry()
+	am, err := createMultitenantAlertmanager(amConfig, nil, prepareInMemoryAlertStore(), ringStore, nil, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, am.ring))
+	t.Cleanup(func() { require.NoError(t, services.StopAndAwaitTerminated(ctx, am.ring)) })
+
+	// The view of the ring of the alertmanager is exported by the ring client.
+	assert.NoError(t, testutil.GatherAndCompare(reg, bytes.NewBufferString(`
+		# HELP cortex_ring_members Number of members in the ring
+		# TYPE cortex_ring_members gauge
+		cortex_ring_members{name="alertmanager",state="ACTIVE"} 2
+		cortex_ring_members{name="alertmanager",state="JOINING"} 0
+		cortex_ring_members{name="alertmanager",state="LEAVING"} 1
+		cortex_ring_members{name="alertmanager",state="PENDING"} 0
+		cortex_ring_members{name="alertmanager",state="Unhealthy"} 0
+
+		# HELP cortex_ring_tokens_owned The number of tokens in the ring owned by the member
+		# TYPE cortex_ring_tokens_owned gauge
+		cortex_ring_tokens_owned{member="alertmanager-1",name="alertmanager"} 128
+		cortex_ring_tokens_owned{member="alertmanager-2",name="alertmanager"} 128
+		cortex_ring_tokens_owned{member="alertmanager-3",name="alertmanager"} 64
+	`), "cortex_ring_members", "cortex_ring_tokens_owned"))
+}
+
+func TestMultitenantAlertmanager_ShouldShardTheTenantsAcrossTheInstances(t *testing.T) {
+	ctx := context.Background()
+	amConfig := mockAlertmanagerConfig(t)
+
+	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	instanceAddrs := map[string]struct{}{}
+	require.NoError(t, ringStore.CAS(ctx, RingKey, func(in interface{}) (interface{}, bool, error) {
+		ringDesc := ring.GetOrCreateRingDesc(in)
+		for i := 0; i < 3; i++ {
+			addr := fmt.Sprintf("127.0.0.%d", i+1)
+			ringDesc.AddIngester(fmt.Sprintf("alertmanager-%d", i), addr, "", ring.GenerateTokens(RingNumTokens, ringDesc.GetTokens()), ring.ACTIVE, time.Now())
+			instanceAddrs[addr] = struct{}{}
+		}
+		return ringDesc, true, nil
+	}))
+
+	am, err := createMultitenantAlertmanager(amConfig, nil, prepareInMemoryAlertStore(), ringStore, nil, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, am.ring))
+	t.Cleanup(func() { require.NoError(t, services.StopAndAwaitTerminated(ctx, am.ring)) })
+
+	owners := simulateTenants(t, am, 1000)
+	require.Len(t, owners, 1000)
+
+	// The tenants are distributed across all the instances.
+	ownedTenants := map[string]int{}
+	for userID, instance := range owners {
+		set, err := am.ring.Get(shardByUser(userID), SyncRingOp, nil, nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, set.Instances[0].Addr, instance.Addr)
+		ownedTenants[instance.Addr]++
+	}
+	require.Len(t, ownedTenants, len(instanceAddrs))
+	for addr := range instanceAddrs {
+		assert.Positive(t, ownedTenants[addr], "instance %s owns no tenant", addr)
+	}
+}
+
+// simulateTenants returns the instance owning each of n synthetic tenants, keyed by tenant
+// ID, as computed from the current state of the ring, to check how the tenants would be
+// sharded across the instances without registering them. The owner of a tenant is the
+// first of its replicas.
+func simulateTenants(t *testing.T, am *MultitenantAlertmanager, n int) map[string]ring.InstanceDesc {
+	owners := make(map[string]ring.InstanceDesc, n)
+	for i := 0; i < n; i++ {
+		userID := fmt.Sprintf("synthetic-tenant-%d", i)
+		set, err := am.ring.Get(shardByUser(userID), SyncRingOp, nil, nil, nil)
+		require.NoError(t, err)
+		require.NotEmpty(t, set.Instances)
+		owners[userID] = set.Instances[0]
+	}
+	return owners
+}
+
+func TestMultitenantAlertmanager_ReadOnlyShouldNotTakeOwnershipOfNewTenants(t *testing.T) {
+	ctx := context.Background()
+	amConfig := mockAlertmanagerConfig(t)
+
+	store := prepareInMemoryAlertStore()
+	userIDs := []string{"user-1", "user-2", "user-3"}
+	for _, userID := range userIDs {
+		require.NoError(t, store.SetAlertConfig(ctx, alertspb.AlertConfigDesc{User: userID, RawConfig: simpleConfigOne}))
+	}
+
+	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	am, err := createMultitenantAlertmanager(amConfig, nil, store, ringStore, nil, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	instanceID, instanceAddr := am.ringLifecycler.GetInstanceID(), am.ringLifecycler.GetInstanceAddr()
+
+	// The instance is the only one in the ring, so it owns all the tenants.
+	require.NoError(t, ringStore.CAS(ctx, RingKey, func(in interface{}) (interface{}, bool, error) {
+		ringDesc := ring.GetOrCreateRingDesc(in)
+		ringDesc.AddIngester(instanceID, instanceAddr, "", ring.GenerateTokens(RingNumTokens, nil), ring.ACTIVE, time.Now())
+		return ringDesc, true, nil
+	}))
+	require.NoError(t, services.StartAndAwaitRunning(ctx, am.ring))
+	t.Cleanup(func() { require.NoError(t, services.StopAndAwaitTerminated(ctx, am.ring)) })
+
+	// The instance is already running the Alertmanager of user-1.
+	am.alertmanagers["user-1"] = &Alertmanager{}
+
+	ownedUsers := func() []string {
+		_, cfgs, err := am.loadAlertmanagerConfigs(ctx)
+		require.NoError(t, err)
+
+		var users []string
+		for userID := range cfgs {
+			users = append(users, userID)
+		}
+		return users
+	}
+
+	// heartbeat runs the heartbeat delegate on the instance stored in the ring, and
+	// returns the stored state.
+	heartbeat := func() ring.InstanceState {
+		var state ring.InstanceState
+		require.NoError(t, ringStore.CAS(ctx, RingKey, func(in interface{}) (interface{}, bool, error) {
+			ringDesc := ring.GetOrCreateRingDesc(in)
+			instance := ringDesc.Ingesters[instanceID]
+			am.OnRingInstanceHeartbeat(nil, ringDesc, &instance)
+			instance.Timestamp = time.Now().Unix()
+			ringDesc.Ingesters[instanceID] = instance
+			state = instance.State
+			return ringDesc, true, nil
+		}))
+		return state
+	}
+
+	require.ElementsMatch(t, userIDs, ownedUsers())
+
+	am.SetReadOnly(true)
+	require.ElementsMatch(t, []string{"user-1"}, ownedUsers())
+
+	// The heartbeat publishes the read-only state in the ring, so that the tenants are
+	// assigned to the other instances.
+	require.Equal(t, ring.LEAVING, heartbeat())
+	require.NoError(t, ringStore.CAS(ctx, RingKey, func(in interface{}) (interface{}, bool, error) {
+		ringDesc := ring.GetOrCreateRingDesc(in)
+		ringDesc.AddIngester("other", "other:9094", "", ring.GenerateTokens(RingNumTokens, nil), ring.ACTIVE, time.Now())
+		return ringDesc, true, nil
+	}))
+	test.Poll(t, time.Second, true, func() interface{} {
+		for _, userID := range userIDs {
+			set, err := am.ring.Get(shardByUser(userID), SyncRingOp, nil, nil, nil)
+			if err != nil || set.Includes(instanceAddr) || !set.Includes("other:9094") {
+				return false
+			}
+		}
+		return true
+	})
+	require.ElementsMatch(t, []string{"user-1"}, ownedUsers())
+	require.Equal(t, ring.LEAVING, heartbeat())
+
+	// Clearing the read-only state sets the instance back ACTIVE in the ring.
+	am.SetReadOnly(false)
+	require.Equal(t, ring.ACTIVE, heartbeat())
+	require.Equal(t, ring.ACTIVE, heartbeat())
+	test.Poll(t, time.Second, true, func() interface{} {
+		set, err := am.ring.GetAllHealthy(RingOp)
+		return err == nil && set.Includes(instanceAddr)
+	})
+}
+
+func TestMultitenantAlertmanager_ReadOnlyHandler(t *testing.T) {
+	am := &MultitenantAlertmanager{logger: log.NewNopLogger()}
+
+	tests := []struct {
+		method           string
+		target           string
+		expectedStatus   int
+		expectedReadOnly bool
+	}{
+		{method: http.MethodGet, target: "/multitenant_alertmanager/read_only", expectedStatus: http.StatusOK, expectedReadOnly: false},
+		{method: http.MethodPost, target: "/multitenant_alertmanager/read_only?read_only=true", expectedStatus: http.StatusOK, expectedReadOnly: true},
+		{method: http.MethodGet, target: "/multitenant_alertmanager/read_only", expectedStatus: http.StatusOK, expectedReadOnly: true},
+		{method: http.MethodPost, target: "/multitenant_alertmanager/read_only?read_only=invalid", expectedStatus: http.StatusBadRequest, expectedReadOnly: true},
+		{method: http.MethodPost, target: "/multitenant_alertmanager/read_only?read_only=false", expectedStatus: http.StatusOK, expectedReadOnly: false},
+	}
+
+	for _, testData := range tests {
+		w := httptest.NewRecorder()
+		am.ReadOnlyHandler(w, httptest.NewRequest(testData.method, testData.target, nil))
+
+		assert.Equal(t, testData.expectedStatus, w.Code, "%s %s", testData.method, testData.target)
+		assert.Equal(t, testData.expectedReadOnly, am.ReadOnly(), "%s %s", testData.method, testData.target)
+		if testData.expectedStatus == http.StatusOK {
+			assert.Equal(t, fmt.Sprintf("read_only: %t\n", testData.expectedReadOnly), w.Body.String())
+		}
+	}
+}
+
 func TestMultitenantAlertmanager_PerTenantSharding(t *testing.T) {
 	tc := []struct {
 		name              string
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// TemplateState is the change of an alertmanager template reported by
+// DiffAlertmanagerConfig.
+type TemplateState int
+
+const (
+	// TemplateCreated denotes a local template the server doesn't have.
+	TemplateCreated TemplateState = iota + 1
+	// TemplateUpdated denotes a template whose local content differs from the server one.
+	TemplateUpdated
+	// TemplateDeleted denotes a template of the server which isn't defined locally.
+	TemplateDeleted
+)
+
+// TemplateDiff is the difference of an alertmanager template.
+type TemplateDiff struct {
+	Name  string
+	State TemplateState
+	// Diff is the unified diff from the server content to the local one.
+	Diff string
+}
+
+// AMDiff holds the differences between a local alertmanager config and the one of the
+// server.
+type AMDiff struct {
+	// Config is the unified diff from the server config to the local one, empty if
+	// they're the same.
+	Config string
+	// Templates are the templates which differ, sorted by name.
+	Templates []TemplateDiff
+}
+
+// Empty returns true if the local alertmanager config is the same as the server one.
+func (d AMDiff) Empty() bool {
+	return d.Config == "" && len(d.Templates) == 0
+}
+
+// DiffAlertmanagerConfig compares the local alertmanager config and templates to the
+// ones of the server, bypassing the alertmanager config cache, without applying them.
+// The changes are reported as the ones applying the local config would make. A tenant
+// without an alertmanager config is compared as an empty config without templates.
+func (r *MimirClient) DiffAlertmanagerConfig(ctx context.Context, localCfg string, localTemplates map[string]string) (AMDiff, error) {
+	remoteCfg, remoteTemplates, err := r.GetAlertmanagerConfig(WithForceRefresh(ctx))
+	if err != nil && !errors.Is(err, ErrResourceNotFound) {
+		return AMDiff{}, errors.Wrap(err, "unable to get the alertmanager config")
+	}
+
+	diff := AMDiff{}
+	if diff.Config, err = unifiedDiff("alertmanager.yaml", remoteCfg, localCfg); err != nil {
+		return AMDiff{}, err
+	}
+
+	for name, local := range localTemplates {
+		remote, ok := remoteTemplates[name]
+		state := TemplateUpdated
+		if !ok {
+			state = TemplateCreated
+		} else if remote == local {
+			continue
+		}
+
+		text, err := unifiedDiff(name, remote, local)
+		if err != nil {
+			return AMDiff{}, err
+		}
+		diff.Templates = append(diff.Templates, TemplateDiff{Name: name, State: state, Diff: text})
+	}
+
+	for name, remote := range remoteTemplates {
+		if _, ok := localTemplates[name]; ok {
+			continue
+		}
+
+		text, err := unifiedDiff(name, remote, "")
+		if err != nil {
+			return AMDiff{}, err
+		}
+		diff.Templates = append(diff.Templates, TemplateDiff{Name: name, State: TemplateDeleted, Diff: text})
+	}
+
+	sort.Slice(diff.Templates, func(i, j int) bool {
+		return diff.Templates[i].Name < diff.Templates[j].Name
+	})
+
+	return diff, nil
+}
+
+// unifiedDiff returns the unified diff from the server content of the file to the local
+// one, empty if they're the same.
+func unifiedDiff(name, remote, local string) (string, error) {
+	if remote == local {
+		return "", nil
+	}
+
+	text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(remote),
+		B:        splitLines(local),
+		FromFile: "remote/" + name,
+		ToFile:   "local/" + name,
+		Context:  3,
+	})
+	return text, errors.Wrapf(err, "unable to diff %s", name)
+}
+
+// splitLines splits the content in lines, keeping their line breaks.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
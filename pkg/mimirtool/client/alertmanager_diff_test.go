@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMimirClient_DiffAlertmanagerConfig(t *testing.T) {
+	remote := configCompat{
+		AlertmanagerConfig: "route:\n  receiver: default\nreceivers:\n  - name: default\n",
+		TemplateFiles: map[string]string{
+			"same.tmpl":    `{{ define "same" }}same{{ end }}`,
+			"updated.tmpl": "{{ define \"updated\" }}\nbefore\n{{ end }}\n",
+			"deleted.tmpl": `{{ define "deleted" }}deleted{{ end }}`,
+		},
+	}
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, "/api/v1/alerts", r.URL.Path)
+		requests++
+
+		body, err := yaml.Marshal(remote)
+		require.NoError(t, err)
+		_, _ = w.Write(body)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	diff, err := client.DiffAlertmanagerConfig(context.Background(),
+		"route:\n  receiver: other\nreceivers:\n  - name: default\n",
+		map[string]string{
+			"same.tmpl":    `{{ define "same" }}same{{ end }}`,
+			"updated.tmpl": "{{ define \"updated\" }}\nafter\n{{ end }}\n",
+			"created.tmpl": "{{ define \"created\" }}created{{ end }}\n",
+		},
+	)
+	require.NoError(t, err)
+	assert.False(t, diff.Empty())
+	assert.Equal(t, 1, requests, "nothing is applied")
+
+	assert.Equal(t, `--- remote/alertmanager.yaml
++++ local/alertmanager.yaml
+@@ -1,4 +1,4 @@
+ route:
+-  receiver: default
++  receiver: other
+ receivers:
+   - name: default
+`, diff.Config)
+
+	assert.Equal(t, []TemplateDiff{
+		{
+			Name:  "created.tmpl",
+			State: TemplateCreated,
+			Diff:  "--- remote/created.tmpl\n+++ local/created.tmpl\n@@ -0,0 +1 @@\n+{{ define \"created\" }}created{{ end }}\n",
+		},
+		{
+			Name:  "deleted.tmpl",
+			State: TemplateDeleted,
+			Diff:  "--- remote/deleted.tmpl\n+++ local/deleted.tmpl\n@@ -1 +0,0 @@\n-{{ define \"deleted\" }}deleted{{ end }}",
+		},
+		{
+			Name:  "updated.tmpl",
+			State: TemplateUpdated,
+			Diff:  "--- remote/updated.tmpl\n+++ local/updated.tmpl\n@@ -1,3 +1,3 @@\n {{ define \"updated\" }}\n-before\n+after\n {{ end }}\n",
+		},
+	}, diff.Templates)
+
+	diff, err = client.DiffAlertmanagerConfig(context.Background(), remote.AlertmanagerConfig, remote.TemplateFiles)
+	require.NoError(t, err)
+	assert.True(t, diff.Empty())
+}
+
+func TestMimirClient_DiffAlertmanagerConfig_NoRemoteConfig(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "alertmanager storage object not found", http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	diff, err := client.DiffAlertmanagerConfig(context.Background(), "route:\n  receiver: default\n", map[string]string{"new.tmpl": "new\n"})
+	require.NoError(t, err)
+	assert.Equal(t, "--- remote/alertmanager.yaml\n+++ local/alertmanager.yaml\n@@ -0,0 +1,2 @@\n+route:\n+  receiver: default\n", diff.Config)
+	require.Len(t, diff.Templates, 1)
+	assert.Equal(t, TemplateCreated, diff.Templates[0].State)
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const alertmanagerRingPath = "/multitenant_alertmanager/ring"
+
+// alertmanagerRingCacheTTL is how long the alertmanager ring fetched to route the requests is
+// cached, so that the ring status page isn't downloaded on every request.
+var alertmanagerRingCacheTTL = 10 * time.Second
+
+// alertmanagerRing is the JSON representation of the alertmanager ring status page.
+type alertmanagerRing struct {
+	Instances []alertmanagerRingInstance `json:"shards"`
+}
+
+type alertmanagerRingInstance struct {
+	ID     string   `json:"id"`
+	State  string   `json:"state"`
+	Tokens []uint32 `json:"tokens"`
+}
+
+type alertmanagerRingToken struct {
+	token    uint32
+	instance string
+}
+
+// alertmanagerRingCache caches the sorted tokens of the ACTIVE instances of the
+// alertmanager ring.
+type alertmanagerRingCache struct {
+	// Held while fetching the ring, so that concurrent calls issue a single request.
+	mtx     sync.Mutex
+	tokens  []alertmanagerRingToken
+	expires time.Time
+}
+
+// withAlertmanagerOwner returns a context targeting the alertmanager replica owning the
+// tenant. The context is returned unchanged if it already targets an instance or if the
+// owner can't be resolved.
+func (r *MimirClient) withAlertmanagerOwner(ctx context.Context) context.Context {
+	if instance, ok := ctx.Value(targetInstanceContextKey).(string); ok && instance != "" {
+		return ctx
+	}
+
+	tenantID := r.requestTenantID(ctx)
+	owner, err := r.alertmanagerOwner(ctx, tenantID)
+	if err != nil {
+		r.logEntry(ctx).WithError(err).WithField("tenant", tenantID).Warnln("unable to resolve the alertmanager owning the tenant, falling back to any replica")
+		return ctx
+	}
+
+	return WithTargetInstance(ctx, owner)
+}
+
+// alertmanagerOwner returns the ID of the alertmanager replica owning the tenant, which
+// is the owner of the first token following the hash of the tenant ID in the ring, out of
+// the ACTIVE instances. Like the alertmanager does, the instances in any other state are
+// skipped, UNHEALTHY included since the ring status page reports it as the state of the
+// instances missing heartbeats. Only this first replica is targeted, even though the
+// tenant is replicated to the following ones too.
+func (r *MimirClient) alertmanagerOwner(ctx context.Context, tenantID string) (string, error) {
+	tokens, err := r.alertmanagerRingTokens(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Tenants are sharded by the FNV-1a hash of their ID, like the alertmanager does.
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(tenantID))
+	key := hasher.Sum32()
+
+	i := sort.Search(len(tokens), func(i int) bool { return tokens[i].token > key })
+	if i == len(tokens) {
+		i = 0
+	}
+	return tokens[i].instance, nil
+}
+
+// alertmanagerRingTokens returns the sorted tokens of the ACTIVE instances of the
+// alertmanager ring, fetching the ring status page once the cached tokens expired.
+func (r *MimirClient) alertmanagerRingTokens(ctx context.Context) ([]alertmanagerRingToken, error) {
+	cache := &r.alertmanagerRingCache
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+
+	if cache.tokens != nil && r.clock.Now().Before(cache.expires) {
+		return cache.tokens, nil
+	}
+
+	res, err := r.doRequest(context.WithValue(ctx, acceptContextKey, "application/json"), alertmanagerRingPath, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	ring := alertmanagerRing{}
+	if err := json.Unmarshal(body, &ring); err != nil {
+		r.logger.WithFields(log.Fields{
+			"body": string(body),
+		}).Debugln("failed to unmarshal alertmanager ring from response")
+
+		return nil, errors.Wrap(err, "unable to unmarshal response")
+	}
+
+	var tokens []alertmanagerRingToken
+	for _, instance := range ring.Instances {
+		if instance.State != "ACTIVE" {
+			continue
+		}
+		for _, token := range instance.Tokens {
+			tokens = append(tokens, alertmanagerRingToken{token: token, instance: instance.ID})
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("no ACTIVE alertmanager in the ring")
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].token < tokens[j].token })
+
+	cache.tokens = tokens
+	cache.expires = r.clock.Now().Add(alertmanagerRingCacheTTL)
+	return tokens, nil
+}
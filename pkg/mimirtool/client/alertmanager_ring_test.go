@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestMimirClient_AlertmanagerRingRouting(t *testing.T) {
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte("my-id"))
+	key := hasher.Sum32()
+	require.Less(t, key, uint32(math.MaxUint32-10))
+
+	// The UNHEALTHY and LEAVING instances own the first tokens following the tenant's, so
+	// the tenant is owned by the next ACTIVE one.
+	ringBody := fmt.Sprintf(`{"shards": [
+		{"id": "am-unhealthy", "state": "UNHEALTHY", "tokens": [%d]},
+		{"id": "am-leaving", "state": "LEAVING", "tokens": [%d]},
+		{"id": "am-owner", "state": "ACTIVE", "tokens": [%d]},
+		{"id": "am-other", "state": "ACTIVE", "tokens": [%d, %d]}
+	]}`, key+1, key+2, key+3, key-1, key+4)
+
+	tests := map[string]struct {
+		ringRouting      bool
+		ringStatus       int
+		targetInstance   string
+		expectedInstance string
+		expectedRingReqs int64
+	}{
+		"ring routing disabled": {
+			ringStatus:       http.StatusOK,
+			expectedInstance: "",
+			expectedRingReqs: 0,
+		},
+		"ring routing enabled": {
+			ringRouting:      true,
+			ringStatus:       http.StatusOK,
+			expectedInstance: "am-owner",
+			expectedRingReqs: 1,
+		},
+		"ring routing enabled, ring endpoint failing": {
+			ringRouting:      true,
+			ringStatus:       http.StatusInternalServerError,
+			expectedInstance: "",
+			expectedRingReqs: 1,
+		},
+		"ring routing enabled, target instance set": {
+			ringRouting:      true,
+			ringStatus:       http.StatusOK,
+			targetInstance:   "am-other",
+			expectedInstance: "am-other",
+			expectedRingReqs: 0,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				ringReqs         = atomic.NewInt64(0)
+				receivedInstance = atomic.NewString("")
+			)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/multitenant_alertmanager/ring":
+					ringReqs.Inc()
+					assert.Equal(t, "application/json", r.Header.Get("Accept"))
+					w.WriteHeader(testData.ringStatus)
+					fmt.Fprint(w, ringBody)
+				case "/api/v1/alerts":
+					receivedInstance.Store(r.Header.Get(targetInstanceHeader))
+					fmt.Fprint(w, "alertmanager_config: config\n")
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer ts.Close()
+
+			client, err := New(Config{Address: ts.URL, ID: "my-id", AlertmanagerRingRouting: testData.ringRouting})
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			if testData.targetInstance != "" {
+				ctx = WithTargetInstance(ctx, testData.targetInstance)
+			}
+
+			cfg, _, err := client.GetAlertmanagerConfig(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, "config", cfg)
+			assert.Equal(t, testData.expectedInstance, receivedInstance.Load())
+			assert.Equal(t, testData.expectedRingReqs, ringReqs.Load())
+		})
+	}
+}
+
+func TestMimirClient_AlertmanagerRingRoutingCache(t *testing.T) {
+	ringReqs := atomic.NewInt64(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/multitenant_alertmanager/ring":
+			ringReqs.Inc()
+			fmt.Fprint(w, `{"shards": [{"id": "am-1", "state": "ACTIVE", "tokens": [1]}]}`)
+		case "/api/v1/alerts":
+			assert.Equal(t, "am-1", r.Header.Get(targetInstanceHeader))
+			fmt.Fprint(w, "alertmanager_config: config\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", AlertmanagerRingRouting: true})
+	require.NoError(t, err)
+	clk := newFakeClock()
+	client.clock = clk
+
+	// The ring is fetched once for all the tenants until it expires.
+	ctx := context.Background()
+	for _, tenantID := range []string{"tenant-1", "tenant-2", "tenant-1"} {
+		_, _, err := client.GetAlertmanagerConfig(withTenantID(ctx, tenantID))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(1), ringReqs.Load())
+
+	clk.Sleep(ctx, alertmanagerRingCacheTTL) //nolint:errcheck
+	_, _, err = client.GetAlertmanagerConfig(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), ringReqs.Load())
+}
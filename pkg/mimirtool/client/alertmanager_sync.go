@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// alertmanagerConfigFile is the name of the file holding the alertmanager config of a
+// tenant, in the directories synced by SyncAlertmanagerConfigs.
+const alertmanagerConfigFile = "config.yaml"
+
+// SyncAlertmanagerConfigs uploads the alertmanager config of each tenant found in dir.
+// Each subdirectory of dir is a tenant, named after the tenant ID, holding the
+// alertmanager config in config.yaml and the templates in any other file, named after the
+// file. The files of dir itself and the nested subdirectories are ignored. The config of
+// each tenant is replaced as a whole, as with CreateAlertmanagerConfig. Tenants whose
+// config can't be read or uploaded are reported in a MultiError, by tenant ID, while an
+// error is returned if dir can't be read.
+func (r *MimirClient) SyncAlertmanagerConfigs(ctx context.Context, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read directory %s", dir)
+	}
+
+	errs := &MultiError{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		tenantID := entry.Name()
+		cfg, templates, err := readAlertmanagerConfigDir(filepath.Join(dir, tenantID))
+		if err == nil {
+			err = errors.Wrap(r.CreateAlertmanagerConfig(withTenantID(ctx, tenantID), cfg, templates), "unable to upload alertmanager config")
+		}
+		if err != nil {
+			errs.Add(tenantID, err)
+			if r.stopOnFirstError {
+				break
+			}
+		}
+	}
+
+	return errs.Err()
+}
+
+// readAlertmanagerConfigDir reads the alertmanager config and the templates of a tenant
+// directory synced by SyncAlertmanagerConfigs.
+func readAlertmanagerConfigDir(dir string) (string, map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "unable to read directory %s", dir)
+	}
+
+	cfg := ""
+	foundCfg := false
+	templates := map[string]string{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "unable to read file %s", entry.Name())
+		}
+
+		if entry.Name() == alertmanagerConfigFile {
+			cfg, foundCfg = string(content), true
+			continue
+		}
+		templates[entry.Name()] = string(content)
+	}
+
+	if !foundCfg {
+		return "", nil, errors.Errorf("no alertmanager config %s found in directory %s", alertmanagerConfigFile, dir)
+	}
+	return cfg, templates, nil
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMimirClient_SyncAlertmanagerConfigs(t *testing.T) {
+	var (
+		uploadsMtx sync.Mutex
+		uploads    = map[string]configCompat{}
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/api/v1/alerts", r.URL.Path)
+
+		tenantID := r.Header.Get("X-Scope-OrgID")
+		if tenantID == "tenant-rejected" {
+			http.Error(w, "invalid alertmanager config", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		cfg := configCompat{}
+		require.NoError(t, yaml.Unmarshal(body, &cfg))
+
+		uploadsMtx.Lock()
+		defer uploadsMtx.Unlock()
+		uploads[tenantID] = cfg
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	writeFile("tenant-1/config.yaml", "route:\n  receiver: tenant-1\nreceivers:\n  - name: tenant-1\n")
+	writeFile("tenant-1/email.tmpl", `{{ define "email" }}tenant-1{{ end }}`)
+	writeFile("tenant-1/slack.tmpl", `{{ define "slack" }}tenant-1{{ end }}`)
+	writeFile("tenant-2/config.yaml", "route:\n  receiver: tenant-2\nreceivers:\n  - name: tenant-2\n")
+	writeFile("README.md", "not a tenant")
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	require.NoError(t, client.SyncAlertmanagerConfigs(context.Background(), dir))
+	assert.Equal(t, map[string]configCompat{
+		"tenant-1": {
+			AlertmanagerConfig: "route:\n  receiver: tenant-1\nreceivers:\n  - name: tenant-1\n",
+			TemplateFiles: map[string]string{
+				"email.tmpl": `{{ define "email" }}tenant-1{{ end }}`,
+				"slack.tmpl": `{{ define "slack" }}tenant-1{{ end }}`,
+			},
+		},
+		"tenant-2": {
+			AlertmanagerConfig: "route:\n  receiver: tenant-2\nreceivers:\n  - name: tenant-2\n",
+			TemplateFiles:      map[string]string{},
+		},
+	}, uploads)
+
+	// The tenants failing are reported, while the other ones are still uploaded.
+	uploads = map[string]configCompat{}
+	writeFile("tenant-missing-config/email.tmpl", "template")
+	writeFile("tenant-rejected/config.yaml", "route: {}\n")
+
+	err = client.SyncAlertmanagerConfigs(context.Background(), dir)
+	merr := &MultiError{}
+	require.ErrorAs(t, err, &merr)
+	require.Len(t, merr.Errors, 2)
+	assert.Equal(t, "tenant-missing-config", merr.Errors[0].Item)
+	assert.Contains(t, merr.Errors[0].Error(), "no alertmanager config config.yaml found")
+	assert.Equal(t, "tenant-rejected", merr.Errors[1].Item)
+	assert.Contains(t, merr.Errors[1].Error(), "invalid alertmanager config")
+	assert.Len(t, uploads, 2)
+}
@@ -7,7 +7,12 @@ package client
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -21,8 +26,13 @@ type configCompat struct {
 	AlertmanagerConfig string            `yaml:"alertmanager_config"`
 }
 
-// CreateAlertmanagerConfig creates a new alertmanager config
+// CreateAlertmanagerConfig creates a new alertmanager config. The routes referencing
+// receivers which are not defined by the config are rejected before uploading it.
 func (r *MimirClient) CreateAlertmanagerConfig(ctx context.Context, cfg string, templates map[string]string) error {
+	if err := validateAlertmanagerReceivers(cfg); err != nil {
+		return err
+	}
+
 	payload, err := yaml.Marshal(&configCompat{
 		TemplateFiles:      templates,
 		AlertmanagerConfig: cfg,
@@ -31,7 +41,12 @@ func (r *MimirClient) CreateAlertmanagerConfig(ctx context.Context, cfg string,
 		return err
 	}
 
-	res, err := r.doRequest(alertmanagerAPIPath, "POST", payload)
+	// The cache is invalidated again once the config is written, as concurrent calls
+	// may have cached the previous config in the meantime.
+	r.invalidateAlertmanagerConfigCache(ctx)
+	defer r.invalidateAlertmanagerConfigCache(ctx)
+
+	res, err := r.doRequest(ctx, alertmanagerAPIPath, "POST", payload)
 	if err != nil {
 		return err
 	}
@@ -43,7 +58,11 @@ func (r *MimirClient) CreateAlertmanagerConfig(ctx context.Context, cfg string,
 
 // DeleteAlermanagerConfig deletes the users alertmanagerconfig
 func (r *MimirClient) DeleteAlermanagerConfig(ctx context.Context) error {
-	res, err := r.doRequest(alertmanagerAPIPath, "DELETE", nil)
+	// As for CreateAlertmanagerConfig, the cache is invalidated before and after the write.
+	r.invalidateAlertmanagerConfigCache(ctx)
+	defer r.invalidateAlertmanagerConfigCache(ctx)
+
+	res, err := r.doRequest(ctx, alertmanagerAPIPath, "DELETE", nil)
 	if err != nil {
 		return err
 	}
@@ -53,11 +72,90 @@ func (r *MimirClient) DeleteAlermanagerConfig(ctx context.Context) error {
 	return nil
 }
 
-// GetAlertmanagerConfig retrieves a rule group
+// alertmanagerConfigCache caches the alertmanager config of each tenant.
+type alertmanagerConfigCache struct {
+	// Held while fetching the config, so that concurrent calls issue a single request.
+	mtx     sync.Mutex
+	entries map[string]cachedAlertmanagerConfig
+}
+
+type cachedAlertmanagerConfig struct {
+	cfg       string
+	templates map[string]string
+	expires   time.Time
+}
+
+// WithForceRefresh returns a context bypassing the alertmanager config cache for the
+// requests issued with it. The fetched config is cached anyway.
+func WithForceRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceRefreshContextKey, true)
+}
+
+// GetAlertmanagerConfig retrieves the alertmanager config and its templates. When the
+// client caches the alertmanager config, a cached config is returned until it expires,
+// unless the context has been created by WithForceRefresh.
 func (r *MimirClient) GetAlertmanagerConfig(ctx context.Context) (string, map[string]string, error) {
-	res, err := r.doRequest(alertmanagerAPIPath, "GET", nil)
+	if r.alertmanagerConfigCacheTTL <= 0 {
+		return r.getAlertmanagerConfig(ctx)
+	}
+
+	tenantID := r.requestTenantID(ctx)
+	forceRefresh, _ := ctx.Value(forceRefreshContextKey).(bool)
+
+	cache := &r.alertmanagerConfigCache
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+
+	if entry, ok := cache.entries[tenantID]; ok && !forceRefresh && r.clock.Now().Before(entry.expires) {
+		return entry.cfg, copyTemplates(entry.templates), nil
+	}
+
+	cfg, templates, err := r.getAlertmanagerConfig(ctx)
 	if err != nil {
-		log.Debugln("no alert config present in response")
+		return "", nil, err
+	}
+
+	if cache.entries == nil {
+		cache.entries = map[string]cachedAlertmanagerConfig{}
+	}
+	cache.entries[tenantID] = cachedAlertmanagerConfig{
+		cfg:       cfg,
+		templates: copyTemplates(templates),
+		expires:   r.clock.Now().Add(r.alertmanagerConfigCacheTTL),
+	}
+
+	return cfg, templates, nil
+}
+
+// invalidateAlertmanagerConfigCache drops the cached alertmanager config of the tenant.
+func (r *MimirClient) invalidateAlertmanagerConfigCache(ctx context.Context) {
+	cache := &r.alertmanagerConfigCache
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+
+	delete(cache.entries, r.requestTenantID(ctx))
+}
+
+func copyTemplates(templates map[string]string) map[string]string {
+	if templates == nil {
+		return nil
+	}
+
+	out := make(map[string]string, len(templates))
+	for name, content := range templates {
+		out[name] = content
+	}
+	return out
+}
+
+func (r *MimirClient) getAlertmanagerConfig(ctx context.Context) (string, map[string]string, error) {
+	if r.alertmanagerRingRouting {
+		ctx = r.withAlertmanagerOwner(ctx)
+	}
+
+	res, err := r.doRequest(ctx, alertmanagerAPIPath, "GET", nil)
+	if err != nil {
+		r.logger.Debugln("no alert config present in response")
 		return "", nil, err
 	}
 
@@ -70,7 +168,7 @@ func (r *MimirClient) GetAlertmanagerConfig(ctx context.Context) (string, map[st
 	compat := configCompat{}
 	err = yaml.Unmarshal(body, &compat)
 	if err != nil {
-		log.WithFields(log.Fields{
+		r.logger.WithFields(log.Fields{
 			"body": string(body),
 		}).Debugln("failed to unmarshal rule group from response")
 
@@ -79,3 +177,104 @@ func (r *MimirClient) GetAlertmanagerConfig(ctx context.Context) (string, map[st
 
 	return compat.AlertmanagerConfig, compat.TemplateFiles, nil
 }
+
+// SetAlertmanagerTemplate adds or replaces a single template of the alertmanager config,
+// keeping the config and the other templates unchanged. The config is read and written
+// back, so concurrent changes to the config may be lost.
+func (r *MimirClient) SetAlertmanagerTemplate(ctx context.Context, name, content string) error {
+	if err := validateTemplateName(name); err != nil {
+		return err
+	}
+
+	cfg, templates, err := r.GetAlertmanagerConfig(WithForceRefresh(ctx))
+	if err != nil {
+		return errors.Wrap(err, "unable to get the alertmanager config")
+	}
+
+	if templates == nil {
+		templates = map[string]string{}
+	}
+	templates[name] = content
+
+	return r.CreateAlertmanagerConfig(ctx, cfg, templates)
+}
+
+// DeleteAlertmanagerTemplate removes a single template from the alertmanager config,
+// keeping the config and the other templates unchanged. Deleting a template which
+// doesn't exist is a no-op.
+func (r *MimirClient) DeleteAlertmanagerTemplate(ctx context.Context, name string) error {
+	if err := validateTemplateName(name); err != nil {
+		return err
+	}
+
+	cfg, templates, err := r.GetAlertmanagerConfig(WithForceRefresh(ctx))
+	if err != nil {
+		return errors.Wrap(err, "unable to get the alertmanager config")
+	}
+
+	if _, ok := templates[name]; !ok {
+		return nil
+	}
+	delete(templates, name)
+
+	return r.CreateAlertmanagerConfig(ctx, cfg, templates)
+}
+
+// validateTemplateName checks that the template name can be safely used as a file
+// name, the same way the Alertmanager does.
+func validateTemplateName(name string) error {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid template name %q: the template name cannot be empty or contain any path", name)
+	}
+	return nil
+}
+
+// alertmanagerRoutingConfig is the part of the alertmanager config defining the routes
+// and the receivers.
+type alertmanagerRoutingConfig struct {
+	Route     *alertmanagerRoute `yaml:"route"`
+	Receivers []struct {
+		Name string `yaml:"name"`
+	} `yaml:"receivers"`
+}
+
+type alertmanagerRoute struct {
+	Receiver string               `yaml:"receiver"`
+	Routes   []*alertmanagerRoute `yaml:"routes"`
+}
+
+// validateAlertmanagerReceivers checks that the receivers of the routes of the
+// alertmanager config, including the nested ones, are defined in the receivers of the
+// config. The routes without a receiver inherit the one of their parent, and the rest
+// of the config is left to the server to validate.
+func validateAlertmanagerReceivers(cfg string) error {
+	parsed := alertmanagerRoutingConfig{}
+	if err := yaml.Unmarshal([]byte(cfg), &parsed); err != nil {
+		return errors.Wrap(err, "unable to parse the alertmanager config")
+	}
+
+	defined := make(map[string]struct{}, len(parsed.Receivers))
+	for _, receiver := range parsed.Receivers {
+		defined[receiver.Name] = struct{}{}
+	}
+
+	var undefined []string
+	var walk func(route *alertmanagerRoute, path string)
+	walk = func(route *alertmanagerRoute, path string) {
+		if route == nil {
+			return
+		}
+		if _, ok := defined[route.Receiver]; route.Receiver != "" && !ok {
+			undefined = append(undefined, fmt.Sprintf("%q (%s)", route.Receiver, path))
+		}
+		for i, child := range route.Routes {
+			walk(child, fmt.Sprintf("%s.routes[%d]", path, i))
+		}
+	}
+	walk(parsed.Route, "route")
+
+	if len(undefined) > 0 {
+		return fmt.Errorf("alertmanager config has routes referencing undefined receivers: %s", strings.Join(undefined, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// newAlertmanagerConfigServer returns a server storing the alertmanager config of a single tenant.
+func newAlertmanagerConfigServer(t *testing.T, initial configCompat) (*httptest.Server, func() configCompat) {
+	var (
+		mtx    sync.Mutex
+		stored = initial
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/alerts", r.URL.Path)
+
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		switch r.Method {
+		case http.MethodGet:
+			out, err := yaml.Marshal(stored)
+			require.NoError(t, err)
+			_, _ = w.Write(out)
+		case http.MethodPost:
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			stored = configCompat{}
+			require.NoError(t, yaml.Unmarshal(body, &stored))
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, func() configCompat {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return stored
+	}
+}
+
+func TestMimirClient_SetAlertmanagerTemplate(t *testing.T) {
+	ts, getStored := newAlertmanagerConfigServer(t, configCompat{
+		AlertmanagerConfig: "route:\n  receiver: default\nreceivers:\n  - name: default\n",
+		TemplateFiles:      map[string]string{"existing.tmpl": "existing"},
+	})
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	require.NoError(t, client.SetAlertmanagerTemplate(context.Background(), "new.tmpl", "new"))
+	assert.Equal(t, configCompat{
+		AlertmanagerConfig: "route:\n  receiver: default\nreceivers:\n  - name: default\n",
+		TemplateFiles:      map[string]string{"existing.tmpl": "existing", "new.tmpl": "new"},
+	}, getStored())
+
+	require.NoError(t, client.SetAlertmanagerTemplate(context.Background(), "existing.tmpl", "updated"))
+	assert.Equal(t, map[string]string{"existing.tmpl": "updated", "new.tmpl": "new"}, getStored().TemplateFiles)
+}
+
+func TestMimirClient_DeleteAlertmanagerTemplate(t *testing.T) {
+	ts, getStored := newAlertmanagerConfigServer(t, configCompat{
+		AlertmanagerConfig: "route:\n  receiver: default\nreceivers:\n  - name: default\n",
+		TemplateFiles:      map[string]string{"first.tmpl": "first", "second.tmpl": "second"},
+	})
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	require.NoError(t, client.DeleteAlertmanagerTemplate(context.Background(), "first.tmpl"))
+	assert.Equal(t, configCompat{
+		AlertmanagerConfig: "route:\n  receiver: default\nreceivers:\n  - name: default\n",
+		TemplateFiles:      map[string]string{"second.tmpl": "second"},
+	}, getStored())
+
+	// Deleting a template which doesn't exist is a no-op.
+	require.NoError(t, client.DeleteAlertmanagerTemplate(context.Background(), "missing.tmpl"))
+	assert.Equal(t, map[string]string{"second.tmpl": "second"}, getStored().TemplateFiles)
+}
+
+func TestMimirClient_AlertmanagerTemplateInvalidName(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	for _, name := range []string{"", ".", "..", "../escape.tmpl", "dir/template.tmpl", "/absolute.tmpl", `dir\template.tmpl`} {
+		assert.Error(t, client.SetAlertmanagerTemplate(context.Background(), name, "content"), "name: %q", name)
+		assert.Error(t, client.DeleteAlertmanagerTemplate(context.Background(), name), "name: %q", name)
+	}
+	assert.Equal(t, 0, requests)
+}
+
+func TestMimirClient_CreateAlertmanagerConfigUndefinedReceiver(t *testing.T) {
+	ts, cfg := newAlertmanagerConfigServer(t, configCompat{})
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	err = client.CreateAlertmanagerConfig(context.Background(), `
+route:
+  receiver: default
+  routes:
+    - matchers: [team="a"]
+      receiver: team-a
+    - matchers: [team="b"]
+      routes:
+        - matchers: [severity="critical"]
+          receiver: team-b-pager
+receivers:
+  - name: default
+  - name: team-a
+`, nil)
+	require.EqualError(t, err, `alertmanager config has routes referencing undefined receivers: "team-b-pager" (route.routes[1].routes[0])`)
+	assert.Empty(t, cfg().AlertmanagerConfig, "the config has not been uploaded")
+}
+
+func TestMimirClient_GetAlertmanagerConfigCache(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		out, err := yaml.Marshal(configCompat{
+			AlertmanagerConfig: "route:\n  receiver: default\nreceivers:\n  - name: default\n",
+			TemplateFiles:      map[string]string{"template.tmpl": "content"},
+		})
+		require.NoError(t, err)
+		_, _ = w.Write(out)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", AlertmanagerConfigCacheTTL: time.Minute})
+	require.NoError(t, err)
+	clk := newFakeClock()
+	client.clock = clk
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		cfg, templates, err := client.GetAlertmanagerConfig(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "route:\n  receiver: default\nreceivers:\n  - name: default\n", cfg)
+		assert.Equal(t, map[string]string{"template.tmpl": "content"}, templates)
+
+		// Changes to the returned templates don't affect the cache.
+		templates["template.tmpl"] = "modified"
+	}
+	assert.Equal(t, 1, requests)
+
+	// Another tenant is cached separately.
+	_, _, err = client.GetAlertmanagerConfig(withTenantID(ctx, "another-id"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests)
+
+	_, _, err = client.GetAlertmanagerConfig(WithForceRefresh(ctx))
+	require.NoError(t, err)
+	assert.Equal(t, 3, requests)
+
+	clk.Sleep(ctx, time.Minute) //nolint:errcheck
+	_, _, err = client.GetAlertmanagerConfig(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, requests)
+
+	// Updating the config invalidates the cache.
+	require.NoError(t, client.CreateAlertmanagerConfig(ctx, "route:\n  receiver: default\nreceivers:\n  - name: default\n", nil))
+	assert.Equal(t, 5, requests)
+	_, _, err = client.GetAlertmanagerConfig(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 6, requests)
+}
+
+func TestMimirClient_GetAlertmanagerConfigCacheConcurrentWrite(t *testing.T) {
+	const (
+		previousConfig = "route:\n  receiver: previous\nreceivers:\n  - name: previous\n"
+		newConfig      = "route:\n  receiver: new\nreceivers:\n  - name: new\n"
+	)
+
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		t.Run(method, func(t *testing.T) {
+			var (
+				mtx    sync.Mutex
+				stored = previousConfig
+			)
+			writeReceived, releaseWrite := make(chan struct{}), make(chan struct{})
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					mtx.Lock()
+					defer mtx.Unlock()
+					out, err := yaml.Marshal(configCompat{AlertmanagerConfig: stored})
+					require.NoError(t, err)
+					_, _ = w.Write(out)
+					return
+				}
+
+				// The write is only applied once a GET has been served in the meantime.
+				close(writeReceived)
+				<-releaseWrite
+				mtx.Lock()
+				defer mtx.Unlock()
+				stored = ""
+				if r.Method == http.MethodPost {
+					stored = newConfig
+				}
+			}))
+			defer ts.Close()
+
+			client, err := New(Config{Address: ts.URL, ID: "my-id", AlertmanagerConfigCacheTTL: time.Hour})
+			require.NoError(t, err)
+			ctx := context.Background()
+
+			writeDone := make(chan error)
+			go func() {
+				if method == http.MethodPost {
+					writeDone <- client.CreateAlertmanagerConfig(ctx, newConfig, nil)
+				} else {
+					writeDone <- client.DeleteAlermanagerConfig(ctx)
+				}
+			}()
+
+			// A GET issued while the write is in progress caches the previous config.
+			<-writeReceived
+			cfg, _, err := client.GetAlertmanagerConfig(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, previousConfig, cfg)
+
+			close(releaseWrite)
+			require.NoError(t, <-writeDone)
+
+			// Once the write is done, the previous config is not served from the cache.
+			cfg, _, err = client.GetAlertmanagerConfig(ctx)
+			require.NoError(t, err)
+			if method == http.MethodPost {
+				assert.Equal(t, newConfig, cfg)
+			} else {
+				assert.Empty(t, cfg)
+			}
+		})
+	}
+}
+
+func TestMimirClient_GetAlertmanagerConfigCacheDisabled(t *testing.T) {
+	ts, _ := newAlertmanagerConfigServer(t, configCompat{AlertmanagerConfig: "route:\n  receiver: default\nreceivers:\n  - name: default\n"})
+
+	requests := 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		ts.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	client, err := New(Config{Address: proxy.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, _, err := client.GetAlertmanagerConfig(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, requests)
+}
+
+func TestMimirClient_AlertmanagerCredentials(t *testing.T) {
+	var (
+		mtx         sync.Mutex
+		credentials = map[string][2]string{}
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, key, _ := r.BasicAuth()
+
+		mtx.Lock()
+		credentials[r.URL.Path] = [2]string{user, key}
+		mtx.Unlock()
+
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	tests := map[string]struct {
+		cfg                  Config
+		expectedAlertmanager [2]string
+		expectedRules        [2]string
+	}{
+		"alertmanager credentials set": {
+			cfg:                  Config{User: "user", Key: "key", AlertmanagerUser: "am-user", AlertmanagerKey: "am-key"},
+			expectedAlertmanager: [2]string{"am-user", "am-key"},
+			expectedRules:        [2]string{"user", "key"},
+		},
+		"only alertmanager key set": {
+			cfg:                  Config{User: "user", Key: "key", AlertmanagerKey: "am-key"},
+			expectedAlertmanager: [2]string{"user", "am-key"},
+			expectedRules:        [2]string{"user", "key"},
+		},
+		"alertmanager credentials not set": {
+			cfg:                  Config{User: "user", Key: "key"},
+			expectedAlertmanager: [2]string{"user", "key"},
+			expectedRules:        [2]string{"user", "key"},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testData.cfg.Address = ts.URL
+			testData.cfg.ID = "my-id"
+			client, err := New(testData.cfg)
+			require.NoError(t, err)
+
+			_, _, err = client.GetAlertmanagerConfig(context.Background())
+			require.ErrorIs(t, err, ErrResourceNotFound)
+			_, err = client.ListRules(context.Background(), "")
+			require.ErrorIs(t, err, ErrResourceNotFound)
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			assert.Equal(t, testData.expectedAlertmanager, credentials["/api/v1/alerts"])
+			assert.Equal(t, testData.expectedRules, credentials["/api/v1/rules"])
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const buildInfoAPIPath = "/api/v1/status/buildinfo"
+
+// BuildInfo holds the build information reported by Grafana Mimir.
+type BuildInfo struct {
+	Application string            `json:"application"`
+	Version     string            `json:"version"`
+	Revision    string            `json:"revision"`
+	Branch      string            `json:"branch"`
+	GoVersion   string            `json:"goVersion"`
+	Features    map[string]string `json:"features"`
+}
+
+type buildInfoResponse struct {
+	Status    string    `json:"status"`
+	BuildInfo BuildInfo `json:"data"`
+}
+
+// BuildInfo retrieves the build information of the Grafana Mimir cluster.
+func (r *MimirClient) BuildInfo(ctx context.Context) (BuildInfo, error) {
+	res, err := r.doRequest(ctx, buildInfoAPIPath, "GET", nil)
+	if err != nil {
+		return BuildInfo{}, err
+	}
+
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return BuildInfo{}, err
+	}
+
+	resp := buildInfoResponse{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		r.logger.WithFields(log.Fields{
+			"body": string(body),
+		}).Debugln("failed to unmarshal build info from response")
+
+		return BuildInfo{}, errors.Wrap(err, "unable to unmarshal response")
+	}
+
+	return resp.BuildInfo, nil
+}
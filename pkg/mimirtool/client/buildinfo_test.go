@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMimirClient_BuildInfo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/status/buildinfo" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"status": "success",
+			"data": {
+				"application": "Grafana Mimir",
+				"version": "2.0.0",
+				"revision": "5e5dc9c",
+				"branch": "HEAD",
+				"goVersion": "go1.17.8",
+				"features": {"ruler_config_api": "true", "query_sharding": "false"}
+			}
+		}`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{
+		Address: ts.URL,
+		ID:      "my-id",
+	})
+	require.NoError(t, err)
+
+	info, err := client.BuildInfo(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, BuildInfo{
+		Application: "Grafana Mimir",
+		Version:     "2.0.0",
+		Revision:    "5e5dc9c",
+		Branch:      "HEAD",
+		GoVersion:   "go1.17.8",
+		Features: map[string]string{
+			"ruler_config_api": "true",
+			"query_sharding":   "false",
+		},
+	}, info)
+}
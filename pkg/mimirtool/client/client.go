@@ -8,17 +8,29 @@ package client
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/grafana/dskit/crypto/tls"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
 )
 
 const (
@@ -27,8 +39,16 @@ const (
 )
 
 var (
-	ErrNoConfig         = errors.New("No config exists for this user")
-	ErrResourceNotFound = errors.New("requested resource not found")
+	authorizationHeaderRegexp = regexp.MustCompile(`(?mi)^(Authorization:)[^\r\n]*`)
+)
+
+var (
+	ErrNoConfig           = errors.New("No config exists for this user")
+	ErrResourceNotFound   = errors.New("requested resource not found")
+	ErrEmptyRuleGroupName = errors.New("rule group name must not be empty")
+
+	ErrConfirmationRequired = errors.New("deleting a namespace requires confirming its name")
+	ErrConflict             = errors.New("the resource has been modified")
 )
 
 // Config is used to configure a MimirClient.
@@ -39,36 +59,315 @@ type Config struct {
 	ID              string `yaml:"id"`
 	TLS             tls.ClientConfig
 	UseLegacyRoutes bool `yaml:"use_legacy_routes"`
+
+	// PinnedCertSHA256, when set, is the SHA-256 fingerprint, hex encoded, of the
+	// certificate the server must present. The TLS handshake fails if the leaf certificate
+	// doesn't match, in addition to the verification of its chain, unless skipped with
+	// TLS.InsecureSkipVerify for example for self-signed certificates.
+	PinnedCertSHA256 string `yaml:"pinned_cert_sha256"`
+
+	// CAPem, when set, is the PEM content of the CA certificates verifying the server
+	// certificate, for example injected through an environment variable rather than a
+	// file. It can't be set along with TLS.CAPath.
+	CAPem string `yaml:"ca_pem"`
+
+	// RulesReadAPI is the API the rules are read from by ListRules and ListRulesFiltered:
+	// config, the default, or prometheus for the Prometheus-compatible rules API served
+	// under /prometheus. The rules are always written with the config API. The rule groups
+	// read from the Prometheus-compatible API only have the fields it reports: their name,
+	// interval and rules.
+	RulesReadAPI string `yaml:"rules_read_api"`
+
+	// AlertmanagerUser and AlertmanagerKey, when set, are used instead of User and Key
+	// to authenticate the requests to the alertmanager API.
+	AlertmanagerUser string `yaml:"alertmanager_user"`
+	AlertmanagerKey  string `yaml:"alertmanager_key"`
+
+	// DumpHTTP logs the full HTTP requests and responses at debug level.
+	// The Authorization header is redacted.
+	DumpHTTP bool `yaml:"dump_http"`
+
+	// RedactBodyPattern, when set, enables the logging of the request bodies at debug
+	// level, with the parts matching the pattern, for example secret label values,
+	// replaced by ***. When nil, the request bodies are not logged.
+	RedactBodyPattern *regexp.Regexp `yaml:"-"`
+
+	// ResponseCache, when set, caches the responses of the GET requests returned with an
+	// ETag. The cached responses are revalidated with If-None-Match, and served from the
+	// cache when the server answers 304 Not Modified.
+	ResponseCache ResponseCache `yaml:"-"`
+
+	// FaultInjector, when set, injects failures in the requests, to test how they're
+	// handled. It's disabled by default, and isn't meant to be set in production.
+	FaultInjector FaultInjector `yaml:"-"`
+
+	// AutoDetectAPIVersion selects the rules API path from the build info reported by
+	// the server, instead of UseLegacyRoutes. The detection happens on the first request
+	// and, if it fails, the path configured by UseLegacyRoutes is used.
+	AutoDetectAPIVersion bool `yaml:"auto_detect_api_version"`
+
+	// MaxRulesPerGroup is the maximum number of rules a group can have to be created.
+	// 0 means unlimited.
+	MaxRulesPerGroup int `yaml:"max_rules_per_group"`
+
+	// RequireLabels and RequireAnnotations are the labels and annotations each alerting
+	// rule must have to be created.
+	RequireLabels      []string `yaml:"require_labels"`
+	RequireAnnotations []string `yaml:"require_annotations"`
+
+	// MinEvaluationInterval is the minimum evaluation interval a rule group can have to be
+	// created. Rule groups without an interval are not checked. 0 means no minimum.
+	MinEvaluationInterval time.Duration `yaml:"min_evaluation_interval"`
+
+	// DefaultEvaluationInterval, when set, is the evaluation interval set to the created rule
+	// groups without one, instead of leaving it to the server default.
+	DefaultEvaluationInterval time.Duration `yaml:"default_evaluation_interval"`
+
+	// RuleTransform, when set, is applied to each rule group before it's validated and
+	// created, for example to add org-wide labels or annotations. An error aborts the
+	// creation of the rule group.
+	RuleTransform func(rwrulefmt.RuleGroup) (rwrulefmt.RuleGroup, error) `yaml:"-"`
+
+	// DialContext is used by the HTTP transport to open connections, allowing for example
+	// to plug in a caching DNS resolver. If nil, the default dialer is used.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error) `yaml:"-"`
+
+	// TLSHandshakeTimeout, ResponseHeaderTimeout and ExpectContinueTimeout bound the
+	// phases of the requests at the transport level, to detect stalled connections
+	// quickly. They are respectively the maximum time to wait for the TLS handshake, for
+	// the response headers once the request is sent, and for the first response headers
+	// when the request asks to Expect: 100-continue. 0 means no timeout.
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout"`
+	ExpectContinueTimeout time.Duration `yaml:"expect_continue_timeout"`
+
+	// UseIdempotencyKeys sends an Idempotency-Key header, derived from the request content,
+	// on POST and DELETE requests, so that they can be safely retried by proxies.
+	UseIdempotencyKeys bool `yaml:"use_idempotency_keys"`
+
+	// RequireDeleteConfirmation requires DeleteNamespace to be called with the name of
+	// the namespace as confirmation, to guard against accidental deletes.
+	RequireDeleteConfirmation bool `yaml:"require_delete_confirmation"`
+
+	// DrainRate is the maximum number of rule groups deleted per second by DrainNamespace,
+	// on top of the retries of the requests rejected by the server rate limits. 0 means
+	// unlimited.
+	DrainRate float64 `yaml:"drain_rate"`
+
+	// AcceptGzip asks the server to gzip compress the responses, which are then
+	// decompressed by the client. It's useful to reduce the size of large listings.
+	AcceptGzip bool `yaml:"accept_gzip"`
+
+	// LenientRuleHealth accepts rule health values unknown to the client when listing the
+	// rule statuses, instead of returning an error. It allows to talk to newer servers.
+	LenientRuleHealth bool `yaml:"lenient_rule_health"`
+
+	// SkipUnchanged makes CreateRuleGroup fetch the current rule group first, and skip the
+	// upload if its content is the same as the one to create, to avoid needless writes when
+	// reconciling the rule groups.
+	SkipUnchanged bool `yaml:"skip_unchanged"`
+
+	// DefaultRequestTimeout bounds the duration of each call to the server, including
+	// the retries and the reading of the response. A deadline set on the context of the
+	// call takes precedence when it's shorter, so that the calls can be given shorter
+	// deadlines than the default one. 0 means no default timeout.
+	DefaultRequestTimeout time.Duration `yaml:"default_request_timeout"`
+
+	// MaxRetries is the maximum number of times a failed request is retried. Only the
+	// GET and DELETE requests, and the POST ones when using idempotency keys, are retried.
+	// 0 disables the retries.
+	MaxRetries int `yaml:"max_retries"`
+
+	// RetryUnsentPOST also retries the POST requests without idempotency keys, but only
+	// when the connection is reset or closed before any response is received, for example
+	// by proxies resetting the connections before forwarding the requests. Note that the
+	// client can't tell whether the request has been processed before the reset.
+	RetryUnsentPOST bool `yaml:"retry_unsent_post"`
+
+	// BackoffStrategy is the strategy computing the delay between retries: constant,
+	// exponential or decorrelated-jitter. Defaults to exponential.
+	BackoffStrategy string `yaml:"backoff_strategy"`
+
+	// MinBackoff and MaxBackoff bound the delay between retries. Default to 100ms and 10s.
+	MinBackoff time.Duration `yaml:"min_backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+
+	// SourceLabels are added to the labels of each rule of the uploaded rule groups, for
+	// example to track the commit and file the rules come from. Labels already set on a
+	// rule are not overwritten. Note that the labels are also added to the alerts and the
+	// series generated by the rules.
+	SourceLabels map[string]string `yaml:"source_labels"`
+
+	// AlertmanagerConfigCacheTTL is how long the alertmanager config fetched by
+	// GetAlertmanagerConfig is cached. 0 disables the cache.
+	AlertmanagerConfigCacheTTL time.Duration `yaml:"alertmanager_config_cache_ttl"`
+
+	// AlertmanagerRingRouting routes the reads of the alertmanager API to the replica
+	// owning the tenant, as resolved from the alertmanager ring status page, which is
+	// cached for 10s. Only the first replica of the tenant is targeted. If the owner
+	// can't be resolved, the requests are served by any replica as usual.
+	AlertmanagerRingRouting bool `yaml:"alertmanager_ring_routing"`
+
+	// JournalPath, when set, is the path of the file where the bulk loads, such as
+	// LoadRuleGroupsFromTar and ImportFromPrometheus, record the rule groups they've
+	// uploaded. A bulk load interrupted, for example by a crash, skips the rule groups
+	// recorded by the previous attempt when resumed. The journal is removed once a bulk
+	// load completes without errors.
+	JournalPath string `yaml:"journal_path"`
+
+	// StopOnFirstError makes the bulk operations, such as ListRulesForTenants,
+	// LoadRuleGroupsFromTar, ImportFromPrometheus and ReplaceNamespace, stop at the first
+	// item failing, rather than carrying on with the other items. The MultiError returned
+	// then only reports that item.
+	StopOnFirstError bool `yaml:"stop_on_first_error"`
+
+	// StripNamespacePrefix, when set, is removed from the names of the namespaces loaded by
+	// LoadRuleGroupsFromTar, for example to import rules exported from a multi-tenant
+	// system with the namespaces prefixed by the tenant ID. Only the namespaces starting
+	// with this exact prefix are renamed.
+	StripNamespacePrefix string `yaml:"strip_namespace_prefix"`
+
+	// MaxInFlight is the maximum number of requests the client sends concurrently, across
+	// all its operations. The requests over the limit wait for a slot. 0 means unlimited.
+	MaxInFlight int `yaml:"max_in_flight"`
+
+	// Registerer, when set, is used to register the metrics of the client, such as the
+	// ones reporting the drift checks of WatchDrift. The clients sharing a registerer
+	// share the metrics.
+	Registerer prometheus.Registerer `yaml:"-"`
+
+	// LogFormat is the format of the logs of the client: text or json. When set, the
+	// client logs with its own logger, with the output and the level the standard logrus
+	// logger has when the client is created. Defaults to the standard logrus logger.
+	LogFormat string `yaml:"log_format"`
+}
+
+// WithDefaults returns a copy of the config with the defaults applied to the fields
+// not set.
+func (cfg Config) WithDefaults() Config {
+	if cfg.BackoffStrategy == "" {
+		cfg.BackoffStrategy = BackoffExponential
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = defaultMinBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	return cfg
 }
 
 // MimirClient is used to get and load rules into a Mimir ruler.
 type MimirClient struct {
-	user     string
-	key      string
-	id       string
+	cfg Config
+
+	user             string
+	key              string
+	alertmanagerUser string
+	alertmanagerKey  string
+	id               string
+
 	endpoint *url.URL
+	srv      *srvEndpoints
 	Client   http.Client
-	apiPath  string
+	dumpHTTP bool
+
+	redactBodyPattern *regexp.Regexp
+
+	useIdempotencyKeys bool
+
+	responseCache ResponseCache
+
+	faultInjector FaultInjector
+
+	requireDeleteConfirmation bool
+
+	drainRate float64
+
+	acceptGzip bool
+
+	lenientRuleHealth bool
+
+	skipUnchanged bool
+
+	sourceLabels map[string]string
+
+	alertmanagerConfigCacheTTL time.Duration
+	alertmanagerConfigCache    alertmanagerConfigCache
+	alertmanagerRingRouting    bool
+	alertmanagerRingCache      alertmanagerRingCache
+
+	journalPath string
+
+	stopOnFirstError bool
+
+	stripNamespacePrefix string
+
+	driftMetrics *driftMetrics
+
+	defaultRequestTimeout time.Duration
+
+	maxRetries      int
+	backoff         retryBackoff
+	retryUnsentPOST bool
+
+	inFlight *semaphore.Weighted
+
+	logger *log.Logger
+
+	clock clock
+
+	maxRulesPerGroup   int
+	requireLabels      []string
+	requireAnnotations []string
+
+	minEvaluationInterval     time.Duration
+	defaultEvaluationInterval time.Duration
+	ruleTransform             func(rwrulefmt.RuleGroup) (rwrulefmt.RuleGroup, error)
+
+	rulesReadAPI string
+
+	autoDetectAPIVersion bool
+	apiPathMtx           sync.Mutex
+	apiPathDetected      bool
+	apiPath              string
 }
 
-// New returns a new MimirClient.
+// New returns a new MimirClient. The address can be of the form srv+<name>, for
+// example srv+_http._tcp.ruler.svc, to balance the requests across the targets of
+// the DNS SRV record, which is periodically resolved again.
 func New(cfg Config) (*MimirClient, error) {
-	endpoint, err := url.Parse(cfg.Address)
+	cfg = cfg.WithDefaults()
+
+	logger, err := newLogger(cfg.LogFormat)
 	if err != nil {
 		return nil, err
 	}
 
-	log.WithFields(log.Fields{
+	var (
+		endpoint *url.URL
+		srv      *srvEndpoints
+	)
+	if scheme, name, ok := parseSRVAddress(cfg.Address); ok {
+		endpoint = &url.URL{Scheme: scheme, Host: name}
+		srv = &srvEndpoints{name: name, resolver: net.DefaultResolver, logger: logger}
+	} else if endpoint, err = url.Parse(cfg.Address); err != nil {
+		return nil, err
+	}
+
+	logger.WithFields(log.Fields{
 		"address": cfg.Address,
 		"id":      cfg.ID,
 	}).Debugln("New ruler client created")
 
-	client := http.Client{}
-
 	// Setup TLS client
+	if cfg.CAPem != "" && cfg.TLS.CAPath != "" {
+		return nil, errors.New("the CA certificates can't be set both as a file and as PEM content")
+	}
+
 	tlsConfig, err := cfg.TLS.GetTLSConfig()
 	if err != nil {
-		log.WithError(err).WithFields(log.Fields{
+		logger.WithError(err).WithFields(log.Fields{
 			"tls-ca":   cfg.TLS.CAPath,
 			"tls-cert": cfg.TLS.CertPath,
 			"tls-key":  cfg.TLS.KeyPath,
@@ -76,12 +375,51 @@ func New(cfg Config) (*MimirClient, error) {
 		return nil, fmt.Errorf("client initialization unsuccessful")
 	}
 
-	if tlsConfig != nil {
-		transport := &http.Transport{
-			Proxy:           http.ProxyFromEnvironment,
-			TLSClientConfig: tlsConfig,
+	if cfg.CAPem != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(cfg.CAPem)) {
+			return nil, errors.New("no valid CA certificate found in the PEM content")
 		}
-		client = http.Client{Transport: transport}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.PinnedCertSHA256 != "" {
+		pinned, err := parseCertFingerprint(cfg.PinnedCertSHA256)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.VerifyPeerCertificate = verifyPinnedCert(pinned)
+	}
+
+	backoff, err := newRetryBackoff(cfg.BackoffStrategy, cfg.MinBackoff, cfg.MaxBackoff)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.RulesReadAPI {
+	case "", RulesReadAPIConfig, RulesReadAPIPrometheus:
+	default:
+		return nil, fmt.Errorf("unknown rules read API %q", cfg.RulesReadAPI)
+	}
+
+	if cfg.DefaultEvaluationInterval > 0 && cfg.DefaultEvaluationInterval < cfg.MinEvaluationInterval {
+		return nil, fmt.Errorf("the default evaluation interval %s is lower than the min evaluation interval %s", cfg.DefaultEvaluationInterval, cfg.MinEvaluationInterval)
+	}
+
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+		DialContext:     cfg.DialContext,
+
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: cfg.ExpectContinueTimeout,
+	}
+	client := http.Client{Transport: transport}
+
+	var inFlight *semaphore.Weighted
+	if cfg.MaxInFlight > 0 {
+		inFlight = semaphore.NewWeighted(int64(cfg.MaxInFlight))
 	}
 
 	path := rulerAPIPath
@@ -90,22 +428,132 @@ func New(cfg Config) (*MimirClient, error) {
 	}
 
 	return &MimirClient{
+		cfg: cfg,
+
 		user:     cfg.User,
 		key:      cfg.Key,
 		id:       cfg.ID,
 		endpoint: endpoint,
+		srv:      srv,
 		Client:   client,
 		apiPath:  path,
+		dumpHTTP: cfg.DumpHTTP,
+
+		alertmanagerUser: cfg.AlertmanagerUser,
+		alertmanagerKey:  cfg.AlertmanagerKey,
+
+		redactBodyPattern: cfg.RedactBodyPattern,
+
+		useIdempotencyKeys: cfg.UseIdempotencyKeys,
+
+		responseCache: cfg.ResponseCache,
+
+		faultInjector: cfg.FaultInjector,
+
+		requireDeleteConfirmation: cfg.RequireDeleteConfirmation,
+
+		drainRate: cfg.DrainRate,
+
+		acceptGzip: cfg.AcceptGzip,
+
+		lenientRuleHealth: cfg.LenientRuleHealth,
+
+		skipUnchanged: cfg.SkipUnchanged,
+
+		sourceLabels: cfg.SourceLabels,
+
+		alertmanagerConfigCacheTTL: cfg.AlertmanagerConfigCacheTTL,
+		alertmanagerRingRouting:    cfg.AlertmanagerRingRouting,
+
+		journalPath: cfg.JournalPath,
+
+		stopOnFirstError: cfg.StopOnFirstError,
+
+		stripNamespacePrefix: cfg.StripNamespacePrefix,
+
+		driftMetrics: newDriftMetrics(cfg.Registerer),
+
+		defaultRequestTimeout: cfg.DefaultRequestTimeout,
+
+		maxRetries:      cfg.MaxRetries,
+		backoff:         backoff,
+		retryUnsentPOST: cfg.RetryUnsentPOST,
+
+		inFlight: inFlight,
+
+		logger: logger,
+
+		clock: realClock{},
+
+		maxRulesPerGroup:   cfg.MaxRulesPerGroup,
+		requireLabels:      cfg.RequireLabels,
+		requireAnnotations: cfg.RequireAnnotations,
+
+		minEvaluationInterval:     cfg.MinEvaluationInterval,
+		defaultEvaluationInterval: cfg.DefaultEvaluationInterval,
+		ruleTransform:             cfg.RuleTransform,
+
+		rulesReadAPI: cfg.RulesReadAPI,
+
+		autoDetectAPIVersion: cfg.AutoDetectAPIVersion,
 	}, nil
 }
 
+// NewSimple returns a new MimirClient for the tenant, authenticated with the API key if
+// not empty, and with the defaults for all the other options. It's meant for quick
+// usages, such as short-lived command line invocations.
+func NewSimple(address, tenantID, apiKey string) (*MimirClient, error) {
+	return New(Config{Address: address, ID: tenantID, Key: apiKey})
+}
+
+// Endpoint returns the address of the Grafana Mimir cluster targeted by the client.
+func (r *MimirClient) Endpoint() string {
+	return r.endpoint.String()
+}
+
+// TenantID returns the ID of the tenant the requests are issued for.
+func (r *MimirClient) TenantID() string {
+	return r.id
+}
+
+// ResolvedConfig returns the config the client uses, with the defaults applied and, once
+// detected, the API version.
+func (r *MimirClient) ResolvedConfig() Config {
+	cfg := r.cfg
+
+	if r.autoDetectAPIVersion {
+		r.apiPathMtx.Lock()
+		if r.apiPathDetected {
+			cfg.UseLegacyRoutes = r.apiPath == legacyAPIPath
+		}
+		r.apiPathMtx.Unlock()
+	}
+
+	return cfg
+}
+
+// credentials returns the user, key and tenant ID to issue a request to the given path with.
+func (r *MimirClient) credentials(path string) (user, key, id string) {
+	user, key, id = r.user, r.key, r.id
+	if strings.HasPrefix(path, alertmanagerAPIPath) {
+		if r.alertmanagerUser != "" {
+			user = r.alertmanagerUser
+		}
+		if r.alertmanagerKey != "" {
+			key = r.alertmanagerKey
+		}
+	}
+
+	return user, key, id
+}
+
 // Query executes a PromQL query against the Mimir cluster.
 func (r *MimirClient) Query(ctx context.Context, query string) (*http.Response, error) {
 
-	query = fmt.Sprintf("query=%s&time=%d", query, time.Now().Unix())
+	query = fmt.Sprintf("query=%s&time=%d", query, r.clock.Now().Unix())
 	escapedQuery := url.PathEscape(query)
 
-	res, err := r.doRequest("/prometheus/api/v1/query?"+escapedQuery, "GET", nil)
+	res, err := r.doRequest(ctx, "/prometheus/api/v1/query?"+escapedQuery, "GET", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -113,28 +561,234 @@ func (r *MimirClient) Query(ctx context.Context, query string) (*http.Response,
 	return res, nil
 }
 
-func (r *MimirClient) doRequest(path, method string, payload []byte) (*http.Response, error) {
-	req, err := buildRequest(path, method, *r.endpoint, payload)
+type contextKey int
+
+const (
+	tenantIDContextKey contextKey = iota
+	targetInstanceContextKey
+	forceRefreshContextKey
+	logFieldsContextKey
+	acceptContextKey
+	ifMatchContextKey
+)
+
+// targetInstanceHeader is the header used to ask Grafana Mimir to route the request
+// to a specific replica.
+const targetInstanceHeader = "X-Mimir-Target-Instance"
+
+// withTenantID returns a context overriding the tenant ID sent in the
+// X-Scope-OrgID header of the requests issued with it.
+func withTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDContextKey, tenantID)
+}
+
+// requestTenantID returns the tenant the requests issued with the context are for.
+func (r *MimirClient) requestTenantID(ctx context.Context) string {
+	if id, ok := ctx.Value(tenantIDContextKey).(string); ok {
+		return id
+	}
+	return r.TenantID()
+}
+
+// WithTargetInstance returns a context asking Grafana Mimir to serve the requests issued
+// with it from the given alertmanager or ruler replica, for example to debug replication lag.
+func WithTargetInstance(ctx context.Context, instance string) context.Context {
+	return context.WithValue(ctx, targetInstanceContextKey, instance)
+}
+
+// WithLogFields returns a context whose fields are added to the log entries of the
+// requests issued with it, for example to correlate them with a trace ID.
+func WithLogFields(ctx context.Context, fields log.Fields) context.Context {
+	return context.WithValue(ctx, logFieldsContextKey, fields)
+}
+
+// Supported log formats.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// newLogger returns the logger of a client logging in the given format, which is the
+// standard logrus logger if not set.
+func newLogger(format string) (*log.Logger, error) {
+	std := log.StandardLogger()
+
+	var formatter log.Formatter
+	switch format {
+	case "":
+		return std, nil
+	case LogFormatText:
+		formatter = &log.TextFormatter{}
+	case LogFormatJSON:
+		formatter = &log.JSONFormatter{}
+	default:
+		return nil, fmt.Errorf("unsupported log format %q, expected %s or %s", format, LogFormatText, LogFormatJSON)
+	}
+
+	return &log.Logger{
+		Out:          std.Out,
+		Hooks:        std.Hooks,
+		Formatter:    formatter,
+		ReportCaller: std.ReportCaller,
+		Level:        std.GetLevel(),
+		ExitFunc:     std.ExitFunc,
+	}, nil
+}
+
+// logEntry returns a log entry with the fields set in the context by WithLogFields.
+func (r *MimirClient) logEntry(ctx context.Context) *log.Entry {
+	fields, _ := ctx.Value(logFieldsContextKey).(log.Fields)
+	return r.logger.WithFields(fields)
+}
+
+// doRequest sends a request to the server, retrying it with backoff when possible.
+func (r *MimirClient) doRequest(ctx context.Context, path, method string, payload []byte) (*http.Response, error) {
+	if r.defaultRequestTimeout <= 0 {
+		return r.doRequestWithRetries(ctx, path, method, payload)
+	}
+
+	// The deadline of the context is kept if it's shorter than the default timeout.
+	ctx, cancel := context.WithTimeout(ctx, r.defaultRequestTimeout)
+	resp, err := r.doRequestWithRetries(ctx, path, method, payload)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
-	if r.user != "" {
-		req.SetBasicAuth(r.user, r.key)
-	} else if r.key != "" {
-		req.SetBasicAuth(r.id, r.key)
+	// The body is read with the context, so it's only canceled once the body is closed.
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnCloseBody is a response body canceling the context of the request once closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (r *MimirClient) doRequestWithRetries(ctx context.Context, path, method string, payload []byte) (*http.Response, error) {
+	var delay time.Duration
+
+	for retry := 0; ; retry++ {
+		resp, err := r.doRequestInFlight(ctx, path, method, payload)
+		if err == nil || retry >= r.maxRetries || !r.isRetryable(ctx, method, err) {
+			return resp, err
+		}
+
+		delay = r.backoff.delay(retry, delay)
+		r.logEntry(ctx).WithError(err).WithFields(log.Fields{
+			"path":   path,
+			"method": method,
+			"retry":  retry + 1,
+			"delay":  delay,
+		}).Warnln("request to Grafana Mimir API failed, retrying")
+
+		if err := r.clock.Sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// doRequestInFlight sends a request to the server once a slot is available, when the
+// number of requests in flight is limited. The slot is held until the response headers
+// are received, and not during the backoff between retries.
+func (r *MimirClient) doRequestInFlight(ctx context.Context, path, method string, payload []byte) (*http.Response, error) {
+	if r.inFlight != nil {
+		if err := r.inFlight.Acquire(ctx, 1); err != nil {
+			return nil, err
+		}
+		defer r.inFlight.Release(1)
 	}
 
-	req.Header.Add("X-Scope-OrgID", r.id)
+	return r.doRequestOnce(ctx, path, method, payload)
+}
 
-	log.WithFields(log.Fields{
+func (r *MimirClient) doRequestOnce(ctx context.Context, path, method string, payload []byte) (*http.Response, error) {
+	endpoint := *r.endpoint
+	if r.srv != nil {
+		host, err := r.srv.host(ctx, r.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		endpoint.Host = host
+	}
+
+	req, err := buildRequest(path, method, endpoint, payload)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	logger := r.logEntry(ctx)
+
+	user, key, id := r.credentials(path)
+	if user != "" {
+		req.SetBasicAuth(user, key)
+	} else if key != "" {
+		req.SetBasicAuth(id, key)
+	}
+
+	tenantID := id
+	if overrideID, ok := ctx.Value(tenantIDContextKey).(string); ok {
+		tenantID = overrideID
+	}
+	req.Header.Add("X-Scope-OrgID", tenantID)
+
+	if instance, ok := ctx.Value(targetInstanceContextKey).(string); ok && instance != "" {
+		req.Header.Set(targetInstanceHeader, instance)
+	}
+
+	if accept, ok := ctx.Value(acceptContextKey).(string); ok {
+		req.Header.Set("Accept", accept)
+	}
+
+	if etag, ok := ctx.Value(ifMatchContextKey).(string); ok {
+		req.Header.Set("If-Match", etag)
+	}
+
+	var (
+		cacheKey string
+		cached   CachedResponse
+		isCached bool
+	)
+	if r.responseCache != nil && method == http.MethodGet {
+		cacheKey = responseCacheKey(tenantID, path)
+		if cached, isCached = r.responseCache.Get(cacheKey); isCached {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+	}
+
+	if r.useIdempotencyKeys && (method == http.MethodPost || method == http.MethodDelete) {
+		req.Header.Set("Idempotency-Key", idempotencyKey(tenantID, method, path, payload))
+	}
+
+	// Setting the header disables the transparent decompression of the transport,
+	// so the response is decompressed below.
+	if r.acceptGzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	logger.WithFields(log.Fields{
 		"url":    req.URL.String(),
 		"method": req.Method,
 	}).Debugln("sending request to Grafana Mimir API")
 
-	resp, err := r.Client.Do(req)
+	if r.redactBodyPattern != nil && len(payload) > 0 {
+		logger.WithField("body", string(r.redactBodyPattern.ReplaceAll(payload, []byte("***")))).Debugln("request body sent to Grafana Mimir API")
+	}
+
+	if r.dumpHTTP {
+		dumpRequest(logger, req)
+	}
+
+	resp, err := r.send(req)
 	if err != nil {
-		log.WithFields(log.Fields{
+		logger.WithFields(log.Fields{
 			"url":    req.URL.String(),
 			"method": req.Method,
 			"error":  err.Error(),
@@ -142,17 +796,75 @@ func (r *MimirClient) doRequest(path, method string, payload []byte) (*http.Resp
 		return nil, err
 	}
 
-	err = checkResponse(resp)
+	if err := decompressResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	if r.dumpHTTP {
+		dumpResponse(logger, resp)
+	}
+
+	if isCached && resp.StatusCode == http.StatusNotModified {
+		logger.WithField("path", path).Debugln("serving response from cache")
+		cachedResponse(resp, cached)
+		return resp, nil
+	}
+
+	err = checkResponse(logger, resp)
 	if err != nil {
 		return nil, err
 	}
 
+	if cacheKey != "" {
+		if err := cacheResponse(r.responseCache, cacheKey, resp); err != nil {
+			return nil, err
+		}
+	}
+
 	return resp, nil
 }
 
+// idempotencyKey returns a key identifying the request by its tenant and content, so
+// that identical requests get the same key, while the same request issued for different
+// tenants doesn't.
+func idempotencyKey(tenantID, method, path string, payload []byte) string {
+	h := sha256.New()
+	h.Write([]byte(tenantID + "\n" + method + " " + path + "\n"))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// dumpRequest logs the raw request, as sent on the wire, with the
+// Authorization header redacted.
+func dumpRequest(logger *log.Entry, req *http.Request) {
+	dump, err := httputil.DumpRequestOut(req, true)
+	if err != nil {
+		logger.WithError(err).Debugln("unable to dump request")
+		return
+	}
+
+	logger.WithField("request", string(redactAuthorization(dump))).Debugln("dumping request to Grafana Mimir API")
+}
+
+// dumpResponse logs the raw response received from the server.
+func dumpResponse(logger *log.Entry, resp *http.Response) {
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		logger.WithError(err).Debugln("unable to dump response")
+		return
+	}
+
+	logger.WithField("response", string(dump)).Debugln("dumping response from Grafana Mimir API")
+}
+
+func redactAuthorization(dump []byte) []byte {
+	return authorizationHeaderRegexp.ReplaceAll(dump, []byte("$1 <redacted>"))
+}
+
 // checkResponse checks the API response for errors
-func checkResponse(r *http.Response) error {
-	log.WithFields(log.Fields{
+func checkResponse(logger *log.Entry, r *http.Response) error {
+	logger.WithFields(log.Fields{
 		"status": r.Status,
 	}).Debugln("checking response")
 	if 200 <= r.StatusCode && r.StatusCode <= 299 {
@@ -172,19 +884,64 @@ func checkResponse(r *http.Response) error {
 	}
 
 	if r.StatusCode == http.StatusNotFound {
-		log.WithFields(log.Fields{
+		logger.WithFields(log.Fields{
 			"status": r.Status,
 			"msg":    msg,
 		}).Debugln(errMsg)
 		return ErrResourceNotFound
 	}
 
-	log.WithFields(log.Fields{
+	logger.WithFields(log.Fields{
 		"status": r.Status,
 		"msg":    msg,
 	}).Errorln(errMsg)
 
-	return errors.New(errMsg)
+	return &statusError{statusCode: r.StatusCode, msg: errMsg}
+}
+
+// decompressResponse replaces the body of a gzip compressed response with
+// the decompressed one.
+func decompressResponse(resp *http.Response) error {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return nil
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "unable to decompress response")
+	}
+
+	resp.Body = &gzipReadCloser{Reader: gz, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
+	return nil
+}
+
+// gzipReadCloser reads a gzip compressed body and closes it once done.
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (r *gzipReadCloser) Close() error {
+	err := r.Reader.Close()
+	if closeErr := r.body.Close(); closeErr != nil {
+		return closeErr
+	}
+	return err
+}
+
+// statusError is returned when the server responds with an unexpected status code.
+type statusError struct {
+	statusCode int
+	msg        string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
 }
 
 func joinPath(baseURLPath, targetPath string) string {
@@ -205,5 +962,13 @@ func buildRequest(p, m string, endpoint url.URL, payload []byte) (*http.Request,
 		endpoint.RawPath = joinPath(endpoint.EscapedPath(), pURL.EscapedPath())
 	}
 	endpoint.Path = joinPath(endpoint.Path, pURL.Path)
-	return http.NewRequest(m, endpoint.String(), bytes.NewBuffer(payload))
+	endpoint.RawQuery = pURL.RawQuery
+
+	// Requests without payload, such as GET and DELETE, are sent without body, and
+	// therefore without Content-Length, which some gateways reject on these methods.
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	return http.NewRequest(m, endpoint.String(), body)
 }
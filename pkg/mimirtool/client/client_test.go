@@ -6,10 +6,25 @@
 package client
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/grafana/dskit/crypto/tls"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -98,3 +113,557 @@ func TestBuildURL(t *testing.T) {
 	}
 
 }
+
+func TestMimirClient_RequestsWithoutPayload(t *testing.T) {
+	endpoint, err := url.Parse("http://mimirurl.com")
+	require.NoError(t, err)
+
+	req, err := buildRequest("/api/v1/rules", http.MethodGet, *endpoint, nil)
+	require.NoError(t, err)
+	assert.Nil(t, req.Body)
+	assert.Zero(t, req.ContentLength)
+
+	type receivedRequest struct {
+		method        string
+		contentLength []string
+	}
+	requestsCh := make(chan receivedRequest, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsCh <- receivedRequest{method: r.Method, contentLength: r.Header.Values("Content-Length")}
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, "namespace: []\n")
+		}
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	_, err = client.ListRules(context.Background(), "")
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group"))
+
+	assert.Equal(t, receivedRequest{method: http.MethodGet}, <-requestsCh)
+	assert.Equal(t, receivedRequest{method: http.MethodDelete}, <-requestsCh)
+}
+
+func TestNewSimple(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, key, ok := r.BasicAuth()
+		if !ok || user != "my-id" || key != "my-api-key" || r.Header.Get("X-Scope-OrgID") != "my-id" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "my-namespace:\n  - name: my-group\n    rules: []\n")
+	}))
+	defer ts.Close()
+
+	client, err := NewSimple(ts.URL, "my-id", "my-api-key")
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL, client.Endpoint())
+	assert.Equal(t, "my-id", client.TenantID())
+
+	rules, err := client.ListRules(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, rules["my-namespace"], 1)
+	assert.Equal(t, "my-group", rules["my-namespace"][0].Name)
+
+	_, err = NewSimple("://invalid", "my-id", "")
+	require.Error(t, err)
+}
+
+func TestMimirClient_DumpHTTP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "dumped-response-body")
+	}))
+	defer ts.Close()
+
+	logs := captureLogs(t)
+
+	client, err := New(Config{
+		Address:  ts.URL,
+		ID:       "my-id",
+		Key:      "my-secret-key",
+		DumpHTTP: true,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group"))
+
+	output := logs.String()
+	assert.Contains(t, output, "DELETE /api/v1/rules/my-namespace/my-group")
+	assert.Contains(t, output, "X-Scope-Orgid: my-id")
+	assert.Contains(t, output, "Authorization: <redacted>")
+	assert.Contains(t, output, "dumped-response-body")
+	assert.NotContains(t, output, "Basic ")
+}
+
+func TestMimirClient_RedactBodyPattern(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	rg := newTestRuleGroup("my-group", "metric:sum")
+	rg.Rules[0].Labels = map[string]string{"team": "my-team", "token": "secret-1234"}
+
+	t.Run("bodies are logged redacted", func(t *testing.T) {
+		client, err := New(Config{
+			Address:           ts.URL,
+			ID:                "my-id",
+			RedactBodyPattern: regexp.MustCompile(`secret-[0-9]+`),
+		})
+		require.NoError(t, err)
+
+		logs := captureLogs(t)
+		require.NoError(t, client.CreateRuleGroup(context.Background(), "my-namespace", rg))
+
+		output := logs.String()
+		assert.Contains(t, output, "token: ***")
+		assert.Contains(t, output, "team: my-team")
+		assert.NotContains(t, output, "secret-1234")
+	})
+
+	t.Run("bodies are not logged without a pattern", func(t *testing.T) {
+		client, err := New(Config{
+			Address: ts.URL,
+			ID:      "my-id",
+		})
+		require.NoError(t, err)
+
+		logs := captureLogs(t)
+		require.NoError(t, client.CreateRuleGroup(context.Background(), "my-namespace", rg))
+
+		output := logs.String()
+		assert.NotContains(t, output, "my-team")
+		assert.NotContains(t, output, "secret-1234")
+	})
+}
+
+func TestMimirClient_WithLogFields(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	logs := captureLogs(t)
+	ctx := WithLogFields(context.Background(), log.Fields{"trace_id": "my-trace-id", "user": "my-user"})
+	require.Error(t, client.DeleteRuleGroup(ctx, "my-namespace", "my-group"))
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.NotEmpty(t, lines)
+	for _, line := range lines {
+		assert.Contains(t, line, "trace_id=my-trace-id")
+		assert.Contains(t, line, "user=my-user")
+	}
+
+	// Requests issued without log fields don't get any.
+	logs.Reset()
+	require.Error(t, client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group"))
+	assert.NotContains(t, logs.String(), "trace_id")
+}
+
+func TestMimirClient_LogFormat(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	logs := captureLogs(t)
+	client, err := New(Config{Address: ts.URL, ID: "my-id", LogFormat: LogFormatJSON})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &map[string]interface{}{}))
+	logs.Reset()
+
+	ctx := WithLogFields(context.Background(), log.Fields{"trace_id": "my-trace-id"})
+	require.Error(t, client.DeleteRuleGroup(ctx, "my-namespace", "my-group"))
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.NotEmpty(t, lines)
+	for _, line := range lines {
+		entry := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		assert.Equal(t, "my-trace-id", entry["trace_id"])
+	}
+
+	// The format of the standard logger is left unchanged.
+	assert.IsType(t, &log.TextFormatter{}, log.StandardLogger().Formatter)
+
+	_, err = New(Config{Address: ts.URL, ID: "my-id", LogFormat: "xml"})
+	assert.EqualError(t, err, `unsupported log format "xml", expected text or json`)
+}
+
+// captureLogs redirects the logrus output to a buffer, at debug level, for
+// the duration of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+
+	prevOut, prevLevel := log.StandardLogger().Out, log.GetLevel()
+	log.SetOutput(&buf)
+	log.SetLevel(log.DebugLevel)
+	t.Cleanup(func() {
+		log.SetOutput(prevOut)
+		log.SetLevel(prevLevel)
+	})
+
+	return &buf
+}
+
+func TestMimirClient_DefaultRequestTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") == "true" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		fmt.Fprint(w, "name: my-group\nrules:\n  - record: metric:sum\n    expr: sum(metric)\n")
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", DefaultRequestTimeout: time.Minute})
+	require.NoError(t, err)
+
+	// The shorter deadline of the context wins over the default timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = client.doRequest(ctx, "/?slow=true", http.MethodGet, nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// The response body can still be read once returned.
+	rg, err := client.GetRuleGroup(context.Background(), "my-namespace", "my-group")
+	require.NoError(t, err)
+	assert.Equal(t, "my-group", rg.Name)
+
+	// The shorter default timeout wins over the deadline of the context.
+	client, err = New(Config{Address: ts.URL, ID: "my-id", DefaultRequestTimeout: 100 * time.Millisecond})
+	require.NoError(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	start = time.Now()
+	_, err = client.doRequest(ctx, "/?slow=true", http.MethodGet, nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestMimirClient_CAPem(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	caPem := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}))
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", CAPem: caPem})
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group"))
+
+	// The server certificate isn't trusted without the CA.
+	client, err = New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+	err = client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate")
+
+	_, err = New(Config{Address: ts.URL, ID: "my-id", CAPem: caPem, TLS: tls.ClientConfig{CAPath: "ca.pem"}})
+	assert.EqualError(t, err, "the CA certificates can't be set both as a file and as PEM content")
+
+	_, err = New(Config{Address: ts.URL, ID: "my-id", CAPem: "not a certificate"})
+	assert.EqualError(t, err, "no valid CA certificate found in the PEM content")
+}
+
+func TestMimirClient_DialContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var dialedAddrs []string
+	dialer := &net.Dialer{}
+
+	client, err := New(Config{
+		Address: ts.URL,
+		ID:      "my-id",
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialedAddrs = append(dialedAddrs, addr)
+			return dialer.DialContext(ctx, network, addr)
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group"))
+	assert.Equal(t, []string{ts.Listener.Addr().String()}, dialedAddrs)
+}
+
+func TestMimirClient_ResponseHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", ResponseHeaderTimeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	transport := client.Client.Transport.(*http.Transport)
+	assert.Equal(t, 50*time.Millisecond, transport.ResponseHeaderTimeout)
+	assert.Zero(t, transport.TLSHandshakeTimeout)
+	assert.Zero(t, transport.ExpectContinueTimeout)
+
+	err = client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timeout awaiting response headers")
+}
+
+func TestMimirClient_MaxInFlight(t *testing.T) {
+	const maxInFlight, numRequests = 2, 10
+
+	var (
+		mtx               sync.Mutex
+		inFlight, maxSeen int
+		release           = make(chan struct{})
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		inFlight++
+		if inFlight > maxSeen {
+			maxSeen = inFlight
+		}
+		mtx.Unlock()
+
+		<-release
+
+		mtx.Lock()
+		inFlight--
+		mtx.Unlock()
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", MaxInFlight: maxInFlight})
+	require.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < numRequests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group"))
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return inFlight == maxInFlight
+	}, time.Second, 10*time.Millisecond)
+
+	// The requests waiting for a slot give up once their context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, client.DeleteRuleGroup(ctx, "my-namespace", "my-group"), context.DeadlineExceeded)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, maxInFlight, maxSeen)
+}
+
+func TestMimirClient_WithTargetInstance(t *testing.T) {
+	requestCh := make(chan *http.Request, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCh <- r
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	t.Run("header is sent when the target instance is set", func(t *testing.T) {
+		ctx := WithTargetInstance(context.Background(), "ruler-1")
+		_, _ = client.GetRuleGroup(ctx, "my-namespace", "my-group")
+
+		req := <-requestCh
+		assert.Equal(t, "ruler-1", req.Header.Get("X-Mimir-Target-Instance"))
+	})
+
+	t.Run("header is not sent when the target instance is not set", func(t *testing.T) {
+		_, _ = client.GetRuleGroup(context.Background(), "my-namespace", "my-group")
+
+		req := <-requestCh
+		assert.NotContains(t, req.Header, "X-Mimir-Target-Instance")
+	})
+}
+
+func TestMimirClient_Getters(t *testing.T) {
+	client, err := New(Config{Address: "https://mimir.example.com/prefix", ID: "my-id", User: "my-user", Key: "my-key"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://mimir.example.com/prefix", client.Endpoint())
+	assert.Equal(t, "my-id", client.TenantID())
+}
+
+func TestConfig_WithDefaults(t *testing.T) {
+	t.Run("zero-value fields get the defaults", func(t *testing.T) {
+		assert.Equal(t, Config{
+			BackoffStrategy: BackoffExponential,
+			MinBackoff:      100 * time.Millisecond,
+			MaxBackoff:      10 * time.Second,
+		}, Config{}.WithDefaults())
+	})
+
+	t.Run("set fields are kept", func(t *testing.T) {
+		cfg := Config{
+			Address:         "http://localhost",
+			MaxRetries:      3,
+			BackoffStrategy: BackoffConstant,
+			MinBackoff:      time.Second,
+			MaxBackoff:      time.Minute,
+		}
+		assert.Equal(t, cfg, cfg.WithDefaults())
+	})
+}
+
+func TestMimirClient_ResolvedConfig(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Servers not reporting the ruler_config_api feature serve the legacy routes.
+		fmt.Fprint(w, `{"status": "success", "data": {"application": "Cortex"}}`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", User: "user", Key: "key", AutoDetectAPIVersion: true})
+	require.NoError(t, err)
+
+	expected := Config{
+		Address:              ts.URL,
+		ID:                   "my-id",
+		User:                 "user",
+		Key:                  "key",
+		AutoDetectAPIVersion: true,
+		BackoffStrategy:      BackoffExponential,
+		MinBackoff:           100 * time.Millisecond,
+		MaxBackoff:           10 * time.Second,
+	}
+	assert.Equal(t, expected, client.ResolvedConfig())
+
+	// The config reflects the detected API version.
+	_, err = client.ListRules(context.Background(), "")
+	require.Error(t, err)
+
+	expected.UseLegacyRoutes = true
+	assert.Equal(t, expected, client.ResolvedConfig())
+}
+
+func TestMimirClient_AcceptGzip(t *testing.T) {
+	const body = "my-namespace:\n  - name: my-group\n    rules:\n      - record: metric:sum\n        expr: sum(metric)\n"
+
+	acceptEncodingCh := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncodingCh <- r.Header.Get("Accept-Encoding")
+
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			fmt.Fprint(w, body)
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		fmt.Fprint(gz, body)
+		require.NoError(t, gz.Close())
+	}))
+	defer ts.Close()
+
+	for _, acceptGzip := range []bool{false, true} {
+		t.Run(fmt.Sprintf("accept gzip: %t", acceptGzip), func(t *testing.T) {
+			client, err := New(Config{Address: ts.URL, ID: "my-id", AcceptGzip: acceptGzip})
+			require.NoError(t, err)
+
+			ruleSet, err := client.ListRules(context.Background(), "")
+			require.NoError(t, err)
+			if acceptGzip {
+				assert.Equal(t, "gzip", <-acceptEncodingCh)
+			} else {
+				<-acceptEncodingCh
+			}
+
+			require.Len(t, ruleSet["my-namespace"], 1)
+			assert.Equal(t, "my-group", ruleSet["my-namespace"][0].Name)
+		})
+	}
+}
+
+type mapResponseCache struct {
+	mtx       sync.Mutex
+	responses map[string]CachedResponse
+}
+
+func (c *mapResponseCache) Get(key string) (CachedResponse, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	resp, ok := c.responses[key]
+	return resp, ok
+}
+
+func (c *mapResponseCache) Set(key string, resp CachedResponse) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.responses[key] = resp
+}
+
+func TestMimirClient_ResponseCache(t *testing.T) {
+	const (
+		body = "name: my-group\nrules:\n  - record: metric:sum\n    expr: sum(metric)\n"
+		etag = `"v1"`
+	)
+
+	var (
+		mtx           sync.Mutex
+		bodiesSent    int
+		notModified   int
+		ifNoneMatches []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		ifNoneMatches = append(ifNoneMatches, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		bodiesSent++
+		w.Header().Set("ETag", etag)
+		fmt.Fprint(w, body)
+	}))
+	defer ts.Close()
+
+	cache := &mapResponseCache{responses: map[string]CachedResponse{}}
+	client, err := New(Config{Address: ts.URL, ID: "my-id", ResponseCache: cache})
+	require.NoError(t, err)
+
+	first, err := client.GetRuleGroup(context.Background(), "my-namespace", "my-group")
+	require.NoError(t, err)
+	second, err := client.GetRuleGroup(context.Background(), "my-namespace", "my-group")
+	require.NoError(t, err)
+
+	assert.Equal(t, "my-group", second.Name)
+	assert.Equal(t, first, second)
+
+	mtx.Lock()
+	assert.Equal(t, []string{"", etag}, ifNoneMatches)
+	assert.Equal(t, 1, bodiesSent)
+	assert.Equal(t, 1, notModified)
+	mtx.Unlock()
+
+	// Requests for other tenants must not be served from the same entry.
+	otherClient, err := New(Config{Address: ts.URL, ID: "other-id", ResponseCache: cache})
+	require.NoError(t, err)
+	_, err = otherClient.GetRuleGroup(context.Background(), "my-namespace", "my-group")
+	require.NoError(t, err)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	assert.Equal(t, 2, bodiesSent)
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// fakeClock is a clock whose time only advances when waiting on it, which
+// returns immediately. The waited durations are recorded.
+type fakeClock struct {
+	mtx    sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.advance(d)
+	return nil
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+}
+
+// Sleeps returns the durations waited so far.
+func (c *fakeClock) Sleeps() []time.Duration {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return append([]time.Duration(nil), c.sleeps...)
+}
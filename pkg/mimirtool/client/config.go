@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadOptions configures how LoadConfigWithOptions reads the config files.
+type LoadOptions struct {
+	// AllowUnknownFields ignores the fields unknown to the client, instead of rejecting
+	// them, for example when the config files embed other settings.
+	AllowUnknownFields bool
+}
+
+// LoadConfig reads the YAML files at the given paths and merges them, in order,
+// into a single Config. Values set in later files override the ones set in earlier
+// files, while values not set in a later file are kept. Unknown fields are rejected,
+// to catch typos.
+func LoadConfig(paths ...string) (Config, error) {
+	return LoadConfigWithOptions(LoadOptions{}, paths...)
+}
+
+// LoadConfigWithOptions reads the YAML files at the given paths like LoadConfig, with
+// the given options.
+func LoadConfigWithOptions(opts LoadOptions, paths ...string) (Config, error) {
+	cfg := Config{}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, errors.Wrapf(err, "unable to read config file %s", path)
+		}
+
+		if err := decodeConfig(content, &cfg, !opts.AllowUnknownFields); err != nil {
+			return Config{}, errors.Wrapf(err, "unable to parse config file %s", path)
+		}
+	}
+
+	return cfg, nil
+}
+
+// decodeConfig decodes the YAML content on top of cfg, so that only the fields
+// set in the content are overridden. If strict, unknown fields are rejected.
+func decodeConfig(content []byte, cfg *Config, strict bool) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(strict)
+
+	err := decoder.Decode(cfg)
+	if err == io.EOF {
+		// An empty file doesn't override anything.
+		return nil
+	}
+	return err
+}
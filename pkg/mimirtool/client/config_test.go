@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	base := filepath.Join(dir, "base.yaml")
+	require.NoError(t, os.WriteFile(base, []byte(`
+address: http://mimir.base:8080
+id: base-tenant
+key: base-key
+tls:
+  tls_ca_path: /etc/ca.pem
+  tls_server_name: mimir.base
+`), 0644))
+
+	override := filepath.Join(dir, "override.yaml")
+	require.NoError(t, os.WriteFile(override, []byte(`
+address: http://mimir.override:8080
+tls:
+  tls_server_name: mimir.override
+`), 0644))
+
+	t.Run("later files override earlier ones", func(t *testing.T) {
+		cfg, err := LoadConfig(base, override)
+		require.NoError(t, err)
+
+		assert.Equal(t, "http://mimir.override:8080", cfg.Address)
+		assert.Equal(t, "base-tenant", cfg.ID)
+		assert.Equal(t, "base-key", cfg.Key)
+		assert.Equal(t, "/etc/ca.pem", cfg.TLS.CAPath)
+		assert.Equal(t, "mimir.override", cfg.TLS.ServerName)
+	})
+
+	t.Run("unknown fields are rejected", func(t *testing.T) {
+		typo := filepath.Join(dir, "typo.yaml")
+		require.NoError(t, os.WriteFile(typo, []byte("adress: http://mimir:8080\n"), 0644))
+
+		_, err := LoadConfig(base, typo)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "adress")
+	})
+
+	t.Run("unknown fields are ignored when allowed", func(t *testing.T) {
+		extra := filepath.Join(dir, "extra.yaml")
+		require.NoError(t, os.WriteFile(extra, []byte("id: extra-tenant\nteam: my-team\n"), 0644))
+
+		_, err := LoadConfigWithOptions(LoadOptions{}, base, extra)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "team")
+
+		cfg, err := LoadConfigWithOptions(LoadOptions{AllowUnknownFields: true}, base, extra)
+		require.NoError(t, err)
+		assert.Equal(t, "http://mimir.base:8080", cfg.Address)
+		assert.Equal(t, "extra-tenant", cfg.ID)
+	})
+
+	t.Run("missing files are reported", func(t *testing.T) {
+		_, err := LoadConfig(filepath.Join(dir, "missing.yaml"))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules"
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+// TenantDiff holds the differences between the rules of two tenants. Only the
+// namespaces which differ are included, sorted by name.
+type TenantDiff struct {
+	Namespaces []rules.NamespaceChange
+}
+
+// Empty returns true if the two tenants have the same rules.
+func (d TenantDiff) Empty() bool {
+	return len(d.Namespaces) == 0
+}
+
+// DiffTenants compares the rules of the tenants targeted by the two clients. The
+// changes are reported as the ones required to turn the rules of a into the rules of b.
+// Expressions are compared once formatted, so that formatting differences are ignored.
+func DiffTenants(ctx context.Context, a, b *MimirClient) (TenantDiff, error) {
+	rulesA, err := listFormattedRules(ctx, a)
+	if err != nil {
+		return TenantDiff{}, errors.Wrap(err, "unable to list the rules of the first tenant")
+	}
+
+	rulesB, err := listFormattedRules(ctx, b)
+	if err != nil {
+		return TenantDiff{}, errors.Wrap(err, "unable to list the rules of the second tenant")
+	}
+
+	return TenantDiff{Namespaces: diffRuleSets(rulesA, rulesB)}, nil
+}
+
+// diffRuleSets returns the changes required to turn the rule set a into the rule set b,
+// for the namespaces which differ, sorted by name.
+func diffRuleSets(rulesA, rulesB map[string][]rwrulefmt.RuleGroup) []rules.NamespaceChange {
+	namespaces := make([]string, 0, len(rulesA)+len(rulesB))
+	for ns := range rulesA {
+		namespaces = append(namespaces, ns)
+	}
+	for ns := range rulesB {
+		if _, ok := rulesA[ns]; !ok {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	sort.Strings(namespaces)
+
+	var changes []rules.NamespaceChange
+	for _, ns := range namespaces {
+		original, inA := rulesA[ns]
+		updated, inB := rulesB[ns]
+
+		change := rules.CompareNamespaces(
+			rules.RuleNamespace{Namespace: ns, Groups: original},
+			rules.RuleNamespace{Namespace: ns, Groups: updated},
+		)
+
+		switch {
+		case change.State == rules.Unchanged:
+			continue
+		case !inA:
+			change.State = rules.Created
+		case !inB:
+			change.State = rules.Deleted
+		}
+
+		sortRuleGroups(change.GroupsCreated)
+		sortRuleGroups(change.GroupsDeleted)
+		sort.Slice(change.GroupsUpdated, func(i, j int) bool {
+			return change.GroupsUpdated[i].New.Name < change.GroupsUpdated[j].New.Name
+		})
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// listFormattedRules lists all the rules of the tenant, with their expressions formatted.
+func listFormattedRules(ctx context.Context, c *MimirClient) (map[string][]rwrulefmt.RuleGroup, error) {
+	ruleSet, err := c.ListRules(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for ns, groups := range ruleSet {
+		namespace := rules.RuleNamespace{Namespace: ns, Groups: groups}
+		if _, _, err := namespace.LintExpressions(rules.MimirBackend); err != nil {
+			return nil, errors.Wrapf(err, "unable to format the expressions of namespace %s", ns)
+		}
+	}
+
+	return ruleSet, nil
+}
+
+func sortRuleGroups(groups []rwrulefmt.RuleGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules"
+)
+
+func newListRulesClient(t *testing.T, body string) *MimirClient {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/rules" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(ts.Close)
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestDiffTenants(t *testing.T) {
+	a := newListRulesClient(t, `
+namespace-1:
+  - name: unchanged
+    rules:
+      - record: metric:sum
+        expr: sum(metric)
+  - name: changed
+    rules:
+      - record: metric:rate
+        expr: rate(metric[1m])
+namespace-2:
+  - name: formatted-differently
+    rules:
+      - record: metric:sum_by_job
+        expr: sum by(job) (metric)
+`)
+	b := newListRulesClient(t, `
+namespace-1:
+  - name: unchanged
+    rules:
+      - record: metric:sum
+        expr: sum(metric)
+  - name: changed
+    rules:
+      - record: metric:rate
+        expr: rate(metric[5m])
+namespace-2:
+  - name: formatted-differently
+    rules:
+      - record: metric:sum_by_job
+        expr: sum(metric)   by   (job)
+`)
+
+	diff, err := DiffTenants(context.Background(), a, b)
+	require.NoError(t, err)
+	assert.False(t, diff.Empty())
+
+	require.Len(t, diff.Namespaces, 1)
+	change := diff.Namespaces[0]
+	assert.Equal(t, "namespace-1", change.Namespace)
+	assert.Equal(t, rules.Updated, change.State)
+	assert.Empty(t, change.GroupsCreated)
+	assert.Empty(t, change.GroupsDeleted)
+
+	require.Len(t, change.GroupsUpdated, 1)
+	assert.Equal(t, "changed", change.GroupsUpdated[0].Original.Name)
+	assert.Equal(t, "rate(metric[1m])", change.GroupsUpdated[0].Original.Rules[0].Expr.Value)
+	assert.Equal(t, "rate(metric[5m])", change.GroupsUpdated[0].New.Rules[0].Expr.Value)
+}
+
+func TestDiffTenants_NamespacesOnlyInOneTenant(t *testing.T) {
+	a := newListRulesClient(t, `
+removed:
+  - name: group
+    rules:
+      - record: metric:sum
+        expr: sum(metric)
+`)
+	b := newListRulesClient(t, `
+added:
+  - name: group
+    rules:
+      - record: metric:sum
+        expr: sum(metric)
+`)
+
+	diff, err := DiffTenants(context.Background(), a, b)
+	require.NoError(t, err)
+
+	require.Len(t, diff.Namespaces, 2)
+	assert.Equal(t, "added", diff.Namespaces[0].Namespace)
+	assert.Equal(t, rules.Created, diff.Namespaces[0].State)
+	require.Len(t, diff.Namespaces[0].GroupsCreated, 1)
+	assert.Equal(t, "removed", diff.Namespaces[1].Namespace)
+	assert.Equal(t, rules.Deleted, diff.Namespaces[1].State)
+	require.Len(t, diff.Namespaces[1].GroupsDeleted, 1)
+}
+
+func TestDiffTenants_SameRules(t *testing.T) {
+	body := `
+namespace:
+  - name: group
+    rules:
+      - record: metric:sum
+        expr: sum(metric)
+`
+
+	diff, err := DiffTenants(context.Background(), newListRulesClient(t, body), newListRulesClient(t, body))
+	require.NoError(t, err)
+	assert.True(t, diff.Empty())
+}
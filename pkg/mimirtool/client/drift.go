@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules"
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+// SyncPlan holds the changes required to sync the rules of a tenant with the rules of a
+// local directory. Only the namespaces which differ are included, sorted by name.
+type SyncPlan struct {
+	Namespaces []rules.NamespaceChange
+}
+
+// Empty returns true if the rules of the tenant are in sync with the local directory.
+func (p SyncPlan) Empty() bool {
+	return len(p.Namespaces) == 0
+}
+
+type driftMetrics struct {
+	groups    *prometheus.GaugeVec
+	lastCheck prometheus.Gauge
+	failures  prometheus.Counter
+}
+
+// newDriftMetrics returns the drift metrics, registered with reg if not nil. The clients
+// sharing the same registerer share the metrics.
+func newDriftMetrics(reg prometheus.Registerer) *driftMetrics {
+	return &driftMetrics{
+		groups: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "mimirtool_rules_drift_groups",
+			Help: "Number of rule groups to create, update or delete to sync the tenant with the local directory, as of the last drift check.",
+		}, []string{"change"})).(*prometheus.GaugeVec),
+		lastCheck: registerOrReuse(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "mimirtool_rules_drift_last_check_timestamp_seconds",
+			Help: "Timestamp of the last successful drift check.",
+		})).(prometheus.Gauge),
+		failures: registerOrReuse(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mimirtool_rules_drift_check_failures_total",
+			Help: "Total number of failed drift checks.",
+		})).(prometheus.Counter),
+	}
+}
+
+// registerOrReuse registers the collector with reg, if not nil, and returns it. If an
+// identical collector is already registered, for example by another client, the existing
+// one is returned instead. It panics on any other registration error, like promauto.
+func registerOrReuse(reg prometheus.Registerer, c prometheus.Collector) prometheus.Collector {
+	if reg == nil {
+		return c
+	}
+
+	if err := reg.Register(c); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			return alreadyRegistered.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
+// Plan returns the changes required to turn the rules of the tenant into the rules of
+// the namespace files found in dir and its subdirectories. Each file is a namespace,
+// named after the file unless the namespace is explicitly set in its content. The local
+// rule groups are compared as they would be uploaded by CreateRuleGroup, and the
+// expressions once formatted. The namespaces of the tenant without a file in dir are
+// reported as deleted.
+func (r *MimirClient) Plan(ctx context.Context, dir string) (SyncPlan, error) {
+	local, err := r.loadDir(dir)
+	if err != nil {
+		return SyncPlan{}, err
+	}
+
+	remote, err := listFormattedRules(ctx, r)
+	if err != nil {
+		return SyncPlan{}, errors.Wrap(err, "unable to list the rules of the tenant")
+	}
+
+	return SyncPlan{Namespaces: diffRuleSets(remote, local)}, nil
+}
+
+// loadDir returns the rule groups of the namespace files found in dir, as they would be
+// uploaded, with their expressions formatted.
+func (r *MimirClient) loadDir(dir string) (map[string][]rwrulefmt.RuleGroup, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ext := filepath.Ext(path); !d.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read directory %s", dir)
+	}
+
+	nss, err := rules.ParseFiles(rules.MimirBackend, files)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse the namespace files of directory %s", dir)
+	}
+
+	ruleSet := make(map[string][]rwrulefmt.RuleGroup, len(nss))
+	for name, ns := range nss {
+		groups := make([]rwrulefmt.RuleGroup, 0, len(ns.Groups))
+		for _, group := range ns.Groups {
+			prepared, _, err := r.ruleGroupPayload(group)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid rule group in file %s", ns.Filepath)
+			}
+			groups = append(groups, prepared)
+		}
+
+		namespace := rules.RuleNamespace{Namespace: name, Groups: groups}
+		if _, _, err := namespace.LintExpressions(rules.MimirBackend); err != nil {
+			return nil, errors.Wrapf(err, "unable to format the expressions of file %s", ns.Filepath)
+		}
+		ruleSet[name] = groups
+	}
+
+	return ruleSet, nil
+}
+
+// WatchDrift computes the plan of dir every interval, calling onDrift with the plan
+// whenever the rules of the tenant aren't in sync with the directory, until the context
+// is done. Failed checks are logged and retried at the next interval. The outcome of the
+// checks is also reported by the metrics registered with Config.Registerer.
+func (r *MimirClient) WatchDrift(ctx context.Context, dir string, interval time.Duration, onDrift func(SyncPlan)) {
+	for {
+		plan, err := r.Plan(ctx, dir)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			r.driftMetrics.failures.Inc()
+			r.logEntry(ctx).WithError(err).WithField("dir", dir).Warnln("unable to check the drift of the rules")
+		default:
+			created, updated, deleted := rules.SummarizeChanges(plan.Namespaces)
+			r.driftMetrics.groups.WithLabelValues("created").Set(float64(created))
+			r.driftMetrics.groups.WithLabelValues("updated").Set(float64(updated))
+			r.driftMetrics.groups.WithLabelValues("deleted").Set(float64(deleted))
+			r.driftMetrics.lastCheck.Set(float64(r.clock.Now().Unix()))
+
+			if !plan.Empty() {
+				r.logEntry(ctx).WithFields(log.Fields{
+					"dir":     dir,
+					"created": created,
+					"updated": updated,
+					"deleted": deleted,
+				}).Infoln("the rules of the tenant drifted from the directory")
+				onDrift(plan)
+			}
+		}
+
+		if err := r.clock.Sleep(ctx, interval); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules"
+)
+
+func TestMimirClient_Plan(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `
+unchanged:
+  - name: group
+    rules:
+      - record: metric:sum
+        expr: sum(metric)
+updated:
+  - name: group
+    rules:
+      - record: metric:sum
+        expr: sum(metric)
+removed:
+  - name: group
+    rules:
+      - record: metric:sum
+        expr: sum(metric)
+`)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	writeNamespaceFile(t, dir, "unchanged.yaml", "sum(metric) ")
+	writeNamespaceFile(t, dir, "updated.yaml", "sum by (job) (metric)")
+	writeNamespaceFile(t, dir, "nested/added.yml", "sum(metric)")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not rules"), 0644))
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	plan, err := client.Plan(context.Background(), dir)
+	require.NoError(t, err)
+	require.Len(t, plan.Namespaces, 3)
+
+	assert.Equal(t, "added", plan.Namespaces[0].Namespace)
+	assert.Equal(t, rules.Created, plan.Namespaces[0].State)
+	assert.Equal(t, "removed", plan.Namespaces[1].Namespace)
+	assert.Equal(t, rules.Deleted, plan.Namespaces[1].State)
+	assert.Equal(t, "updated", plan.Namespaces[2].Namespace)
+	assert.Equal(t, rules.Updated, plan.Namespaces[2].State)
+	require.Len(t, plan.Namespaces[2].GroupsUpdated, 1)
+	assert.Equal(t, "sum by(job) (metric)", plan.Namespaces[2].GroupsUpdated[0].New.Rules[0].Expr.Value)
+}
+
+func TestMimirClient_WatchDrift(t *testing.T) {
+	var (
+		remoteMtx sync.Mutex
+		remote    = "sum(metric)"
+		checks    int
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteMtx.Lock()
+		defer remoteMtx.Unlock()
+
+		// The rules of the tenant are changed after the second check.
+		checks++
+		if checks == 3 {
+			remote = "count(metric)"
+		}
+		fmt.Fprintf(w, "namespace:\n  - name: group\n    rules:\n      - record: metric:sum\n        expr: %s\n", remote)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	writeNamespaceFile(t, dir, "namespace.yaml", "sum(metric)")
+
+	reg := prometheus.NewPedanticRegistry()
+	client, err := New(Config{Address: ts.URL, ID: "my-id", Registerer: reg})
+	require.NoError(t, err)
+	clock := newFakeClock()
+	client.clock = clock
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var plans []SyncPlan
+	client.WatchDrift(ctx, dir, time.Minute, func(plan SyncPlan) {
+		plans = append(plans, plan)
+		cancel()
+	})
+
+	require.Len(t, plans, 1)
+	require.Len(t, plans[0].Namespaces, 1)
+	assert.Equal(t, rules.Updated, plans[0].Namespaces[0].State)
+	assert.Equal(t, []time.Duration{time.Minute, time.Minute}, clock.Sleeps())
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+		# HELP mimirtool_rules_drift_groups Number of rule groups to create, update or delete to sync the tenant with the local directory, as of the last drift check.
+		# TYPE mimirtool_rules_drift_groups gauge
+		mimirtool_rules_drift_groups{change="created"} 0
+		mimirtool_rules_drift_groups{change="deleted"} 0
+		mimirtool_rules_drift_groups{change="updated"} 1
+		# HELP mimirtool_rules_drift_last_check_timestamp_seconds Timestamp of the last successful drift check.
+		# TYPE mimirtool_rules_drift_last_check_timestamp_seconds gauge
+		mimirtool_rules_drift_last_check_timestamp_seconds %d
+	`, clock.Now().Unix())), "mimirtool_rules_drift_groups", "mimirtool_rules_drift_last_check_timestamp_seconds"))
+}
+
+func TestNewDriftMetrics_SharedRegisterer(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+
+	var clients []*MimirClient
+	for i := 0; i < 2; i++ {
+		require.NotPanics(t, func() {
+			client, err := New(Config{Address: "http://localhost", ID: "my-id", Registerer: reg})
+			require.NoError(t, err)
+			clients = append(clients, client)
+		})
+	}
+
+	// The clients share the metrics.
+	clients[0].driftMetrics.failures.Inc()
+	clients[1].driftMetrics.failures.Inc()
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP mimirtool_rules_drift_check_failures_total Total number of failed drift checks.
+		# TYPE mimirtool_rules_drift_check_failures_total counter
+		mimirtool_rules_drift_check_failures_total 2
+	`), "mimirtool_rules_drift_check_failures_total"))
+
+	// Conflicting metrics still panic.
+	require.NoError(t, reg.Register(prometheus.NewCounter(prometheus.CounterOpts{Name: "conflicting", Help: "Conflicting metric."})))
+	assert.Panics(t, func() {
+		registerOrReuse(reg, prometheus.NewCounter(prometheus.CounterOpts{Name: "conflicting", Help: "Another help."}))
+	})
+}
+
+func writeNamespaceFile(t *testing.T, dir, name, expr string) {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	content := fmt.Sprintf("groups:\n  - name: group\n    rules:\n      - record: metric:sum\n        expr: %s\n", expr)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParseError is the error of a YAML document returned by the server which can't be
+// decoded, with the location of the failure in the document when known.
+type ParseError struct {
+	// Line and Column are 1-based, and 0 when unknown.
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	default:
+		return e.Msg
+	}
+}
+
+// ItemError is the error of a single item of a bulk operation.
+type ItemError struct {
+	// Item identifies the item which failed, for example a tenant ID or a rule group.
+	Item string
+	Err  error
+}
+
+func (e ItemError) Error() string {
+	return e.Item + ": " + e.Err.Error()
+}
+
+func (e ItemError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is returned by the bulk operations when some of the items fail. The
+// failed items can be inspected with errors.As, while the other ones succeeded.
+type MultiError struct {
+	Errors []ItemError
+}
+
+// Add records the failure of an item.
+func (e *MultiError) Add(item string, err error) {
+	e.Errors = append(e.Errors, ItemError{Item: item, Err: err})
+}
+
+// Err returns the MultiError if any item failed, nil otherwise.
+func (e *MultiError) Err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *MultiError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is returns whether the error of any failed item matches the target, so that errors.Is
+// looks into the items with the Go versions not following Unwrap() []error.
+func (e *MultiError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of the failed items matching the target, so that errors.As
+// looks into the items with the Go versions not following Unwrap() []error.
+func (e *MultiError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the errors of the failed items.
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Scope-OrgID") {
+		case "tenant-1":
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		case "tenant-2":
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	_, err = client.ListRulesForTenants(context.Background(), []string{"tenant-1", "tenant-2", "tenant-3"}, nil)
+	require.Error(t, err)
+
+	var multiErr *MultiError
+	require.True(t, errors.As(err, &multiErr))
+
+	failed := map[string]error{}
+	for _, itemErr := range multiErr.Errors {
+		failed[itemErr.Item] = itemErr.Err
+	}
+	require.Len(t, failed, 2)
+	assert.Contains(t, failed["tenant-1"].Error(), "internal error")
+	assert.True(t, errors.Is(failed["tenant-2"], ErrResourceNotFound))
+
+	assert.Len(t, multiErr.Unwrap(), 2)
+	assert.True(t, errors.Is(err, ErrResourceNotFound))
+
+	// The items are matched by the methods of MultiError, whatever the Go version.
+	assert.True(t, multiErr.Is(ErrResourceNotFound))
+	assert.False(t, multiErr.Is(ErrEmptyRuleGroupName))
+
+	var statusErr *statusError
+	require.True(t, multiErr.As(&statusErr))
+	assert.Equal(t, http.StatusInternalServerError, statusErr.statusCode)
+	statusErr = nil
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusInternalServerError, statusErr.statusCode)
+}
+
+func TestMultiError_Err(t *testing.T) {
+	errs := &MultiError{}
+	assert.NoError(t, errs.Err())
+
+	errs.Add("item-1", errors.New("first"))
+	errs.Add("item-2", errors.New("second"))
+	assert.EqualError(t, errs.Err(), "item-1: first; item-2: second")
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// TokenRange is a range of the 32-bit token space of the rule groups, from Start
+// included to End excluded.
+type TokenRange struct {
+	Start uint64
+	End   uint64
+}
+
+// Contains returns whether the token is in the range.
+func (r TokenRange) Contains(token uint32) bool {
+	return uint64(token) >= r.Start && uint64(token) < r.End
+}
+
+// ruleGroupToken returns the token of the rule group in the token space, the hash of its
+// namespace and name, as used by ListOptions to shard the rule groups.
+func ruleGroupToken(namespace, group string) uint32 {
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(namespace + "/" + group))
+	return hasher.Sum32()
+}
+
+// ExportShard returns the token range handled by the worker of the given index, out of
+// count workers exporting the rule groups of a tenant in parallel, along with a predicate
+// returning whether a rule group belongs to the worker. The token space is split in count
+// contiguous ranges of the same size, so that the workers export disjoint subsets of the
+// rule groups covering all of them, without coordinating with each other.
+func ExportShard(index, count int) (TokenRange, func(namespace, group string) bool, error) {
+	if count <= 0 || index < 0 || index >= count {
+		return TokenRange{}, nil, fmt.Errorf("invalid export worker %d of %d", index, count)
+	}
+
+	const tokenSpace = uint64(math.MaxUint32) + 1
+	tokens := TokenRange{
+		Start: uint64(index) * tokenSpace / uint64(count),
+		End:   uint64(index+1) * tokenSpace / uint64(count),
+	}
+	return tokens, func(namespace, group string) bool {
+		return tokens.Contains(ruleGroupToken(namespace, group))
+	}, nil
+}
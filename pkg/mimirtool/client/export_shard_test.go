@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportShard(t *testing.T) {
+	for _, count := range []int{1, 3, 7, 16} {
+		t.Run(fmt.Sprintf("%d workers", count), func(t *testing.T) {
+			belongs := make([]func(namespace, group string) bool, 0, count)
+
+			// The ranges are contiguous and cover the whole token space.
+			var next uint64
+			for i := 0; i < count; i++ {
+				tokens, belongsTo, err := ExportShard(i, count)
+				require.NoError(t, err)
+				assert.Equal(t, next, tokens.Start)
+				assert.Greater(t, tokens.End, tokens.Start)
+				next = tokens.End
+				belongs = append(belongs, belongsTo)
+			}
+			assert.Equal(t, uint64(math.MaxUint32)+1, next)
+
+			// Each rule group belongs to exactly one worker.
+			for i := 0; i < 1000; i++ {
+				namespace, group := fmt.Sprintf("namespace-%d", i%10), fmt.Sprintf("group-%d", i)
+				owners := 0
+				for _, belongsTo := range belongs {
+					if belongsTo(namespace, group) {
+						owners++
+					}
+				}
+				assert.Equal(t, 1, owners, "rule group %s/%s", namespace, group)
+			}
+		})
+	}
+
+	for _, shard := range [][2]int{{0, 0}, {-1, 2}, {2, 2}} {
+		_, _, err := ExportShard(shard[0], shard[1])
+		assert.EqualError(t, err, fmt.Sprintf("invalid export worker %d of %d", shard[0], shard[1]))
+	}
+}
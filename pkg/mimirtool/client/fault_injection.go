@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FaultInjector injects failures in the requests sent to the server, to test how the
+// callers handle them. The faults are injected on each attempt, and the injected
+// responses are handled as the ones of the server, including the retries.
+// Implementations must be safe for concurrent use.
+type FaultInjector interface {
+	// Fault returns the fault to inject in the request, or the zero Fault to send it
+	// as is.
+	Fault(req *http.Request) Fault
+}
+
+// Fault is a failure injected in a request by a FaultInjector.
+type Fault struct {
+	// Delay delays the request, before it's sent or failed.
+	Delay time.Duration
+
+	// Err fails the request with the error, as if it couldn't be sent.
+	Err error
+
+	// StatusCode, when not 0, fails the request with a response with this status code,
+	// without sending it to the server.
+	StatusCode int
+}
+
+// injectedFaultMessage is the body of the responses injected by a FaultInjector.
+const injectedFaultMessage = "injected fault"
+
+// send sends the request, unless the fault injector fails it.
+func (r *MimirClient) send(req *http.Request) (*http.Response, error) {
+	if r.faultInjector == nil {
+		return r.Client.Do(req)
+	}
+
+	fault := r.faultInjector.Fault(req)
+	if fault.Delay > 0 {
+		if err := r.clock.Sleep(req.Context(), fault.Delay); err != nil {
+			return nil, err
+		}
+	}
+	if fault.Err != nil {
+		return nil, fault.Err
+	}
+	if fault.StatusCode != 0 {
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", fault.StatusCode, http.StatusText(fault.StatusCode)),
+			StatusCode: fault.StatusCode,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(injectedFaultMessage)),
+			Request:    req,
+		}, nil
+	}
+	return r.Client.Do(req)
+}
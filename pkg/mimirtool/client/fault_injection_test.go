@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+type faultInjectorFunc func(req *http.Request) Fault
+
+func (f faultInjectorFunc) Fault(req *http.Request) Fault {
+	return f(req)
+}
+
+func TestMimirClient_FaultInjector(t *testing.T) {
+	received := atomic.NewInt32(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Inc()
+	}))
+	defer ts.Close()
+
+	// Every third request fails with a 500.
+	requests := atomic.NewInt32(0)
+	injector := faultInjectorFunc(func(req *http.Request) Fault {
+		if requests.Inc()%3 == 0 {
+			return Fault{StatusCode: http.StatusInternalServerError}
+		}
+		return Fault{}
+	})
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", FaultInjector: injector})
+	require.NoError(t, err)
+
+	var failed int
+	for i := 0; i < 6; i++ {
+		err := client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group")
+		if err == nil {
+			continue
+		}
+		failed++
+
+		var statusErr *statusError
+		require.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, http.StatusInternalServerError, statusErr.statusCode)
+		assert.Contains(t, err.Error(), injectedFaultMessage)
+	}
+	assert.Equal(t, 2, failed)
+	assert.Equal(t, int32(4), received.Load())
+
+	// The injected failures are retried as the ones of the server.
+	client, err = New(Config{Address: ts.URL, ID: "my-id", FaultInjector: injector, MaxRetries: 1})
+	require.NoError(t, err)
+	clk := newFakeClock()
+	client.clock = clk
+
+	requests.Store(2)
+	received.Store(0)
+	require.NoError(t, client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group"))
+	assert.Equal(t, int32(1), received.Load())
+	assert.Len(t, clk.Sleeps(), 1)
+
+	// The requests can also be delayed, or failed with an error.
+	injectedErr := errors.New("connection refused")
+	client, err = New(Config{Address: ts.URL, ID: "my-id", FaultInjector: faultInjectorFunc(func(req *http.Request) Fault {
+		return Fault{Delay: time.Second, Err: injectedErr}
+	})})
+	require.NoError(t, err)
+	clk = newFakeClock()
+	client.clock = clk
+
+	received.Store(0)
+	err = client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group")
+	assert.ErrorIs(t, err, injectedErr)
+	assert.Equal(t, []time.Duration{time.Second}, clk.Sleeps())
+	assert.Zero(t, received.Load())
+}
@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+const (
+	// grafanaQueryRefID is the ref ID of the query of the exported rules, and
+	// grafanaConditionRefID the one of the expression evaluating it as the condition.
+	grafanaQueryRefID     = "query"
+	grafanaConditionRefID = "condition"
+
+	// grafanaExpressionDatasourceUID is the UID of the Grafana server-side expressions.
+	grafanaExpressionDatasourceUID = "__expr__"
+
+	// grafanaDefaultInterval is the evaluation interval of the exported rule groups without
+	// one, as the default of the ruler.
+	grafanaDefaultInterval = time.Minute
+
+	// grafanaQueryTimeRange is the time range of the queries, which are instant queries.
+	grafanaQueryTimeRange = 10 * time.Minute
+)
+
+type grafanaRuleGroup struct {
+	Name     string        `json:"name"`
+	Interval string        `json:"interval"`
+	Rules    []grafanaRule `json:"rules"`
+}
+
+type grafanaRule struct {
+	For          string            `json:"for,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	GrafanaAlert grafanaAlert      `json:"grafana_alert"`
+}
+
+type grafanaAlert struct {
+	Title        string         `json:"title"`
+	Condition    string         `json:"condition,omitempty"`
+	Data         []grafanaQuery `json:"data"`
+	NoDataState  string         `json:"no_data_state,omitempty"`
+	ExecErrState string         `json:"exec_err_state,omitempty"`
+	Record       *grafanaRecord `json:"record,omitempty"`
+}
+
+type grafanaRecord struct {
+	Metric string `json:"metric"`
+	From   string `json:"from"`
+}
+
+type grafanaQuery struct {
+	RefID             string                 `json:"refId"`
+	DatasourceUID     string                 `json:"datasourceUid"`
+	RelativeTimeRange grafanaTimeRange       `json:"relativeTimeRange"`
+	Model             map[string]interface{} `json:"model"`
+}
+
+type grafanaTimeRange struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// ExportGrafanaRules returns the rule groups of the tenant converted to the Grafana-managed
+// rules format, for example to migrate them to Grafana-managed alerting. The output is the
+// JSON object returned by the Grafana ruler API, with the rule groups keyed by namespace,
+// to be created in the folder of the same name. The rule expressions are instant queries
+// of the Prometheus data source with the given UID.
+//
+// The alerting rules keep their name as title, and their for duration, labels and
+// annotations. Their condition is true for any series returned by the query, as with
+// Prometheus, and no data is not alerting. The recording rules are exported as Grafana
+// recording rules.
+func (r *MimirClient) ExportGrafanaRules(ctx context.Context, datasourceUID string) ([]byte, error) {
+	ruleSet, err := r.ListRules(ctx, "")
+	if err != nil && !errors.Is(err, ErrResourceNotFound) {
+		return nil, errors.Wrap(err, "unable to list rules")
+	}
+
+	exported := make(map[string][]grafanaRuleGroup, len(ruleSet))
+	for ns, groups := range ruleSet {
+		groups = append([]rwrulefmt.RuleGroup(nil), groups...)
+		sortRuleGroups(groups)
+
+		converted := make([]grafanaRuleGroup, 0, len(groups))
+		for _, rg := range groups {
+			converted = append(converted, toGrafanaRuleGroup(rg, datasourceUID))
+		}
+		exported[ns] = converted
+	}
+
+	// The expressions are not HTML escaped, so that comparison operators are kept as is.
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(exported); err != nil {
+		return nil, errors.Wrap(err, "unable to marshal rules")
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func toGrafanaRuleGroup(rg rwrulefmt.RuleGroup, datasourceUID string) grafanaRuleGroup {
+	interval := time.Duration(rg.Interval)
+	if interval == 0 {
+		interval = grafanaDefaultInterval
+	}
+
+	group := grafanaRuleGroup{
+		Name:     rg.Name,
+		Interval: model.Duration(interval).String(),
+		Rules:    make([]grafanaRule, 0, len(rg.Rules)),
+	}
+
+	for _, rule := range rg.Rules {
+		query := grafanaQuery{
+			RefID:             grafanaQueryRefID,
+			DatasourceUID:     datasourceUID,
+			RelativeTimeRange: grafanaTimeRange{From: int64(grafanaQueryTimeRange / time.Second)},
+			Model: map[string]interface{}{
+				"refId":   grafanaQueryRefID,
+				"expr":    rule.Expr.Value,
+				"instant": true,
+				"range":   false,
+			},
+		}
+
+		converted := grafanaRule{Labels: rule.Labels}
+		if rule.Record.Value != "" {
+			converted.GrafanaAlert = grafanaAlert{
+				Title:  rule.Record.Value,
+				Data:   []grafanaQuery{query},
+				Record: &grafanaRecord{Metric: rule.Record.Value, From: grafanaQueryRefID},
+			}
+			group.Rules = append(group.Rules, converted)
+			continue
+		}
+
+		if rule.For != 0 {
+			converted.For = rule.For.String()
+		}
+		converted.Annotations = rule.Annotations
+		converted.GrafanaAlert = grafanaAlert{
+			Title:     rule.Alert.Value,
+			Condition: grafanaConditionRefID,
+			Data: []grafanaQuery{query, {
+				RefID:         grafanaConditionRefID,
+				DatasourceUID: grafanaExpressionDatasourceUID,
+				Model: map[string]interface{}{
+					"refId":      grafanaConditionRefID,
+					"type":       "math",
+					"expression": "is_number($query) || is_nan($query) || is_inf($query)",
+				},
+			}},
+			NoDataState:  "OK",
+			ExecErrState: "Error",
+		}
+		group.Rules = append(group.Rules, converted)
+	}
+
+	return group
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMimirClient_ExportGrafanaRules(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/rules", r.URL.Path)
+		fmt.Fprint(w, `
+my-namespace:
+  - name: my-group
+    interval: 30s
+    rules:
+      - alert: InstanceDown
+        expr: up < 1
+        for: 5m
+        labels:
+          severity: critical
+        annotations:
+          summary: Instance {{ $labels.instance }} is down
+  - name: my-recording-group
+    rules:
+      - record: job:up:sum
+        expr: sum by(job) (up)
+`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	exported, err := client.ExportGrafanaRules(context.Background(), "my-datasource")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"my-namespace": [
+			{
+				"name": "my-group",
+				"interval": "30s",
+				"rules": [
+					{
+						"for": "5m",
+						"labels": {"severity": "critical"},
+						"annotations": {"summary": "Instance {{ $labels.instance }} is down"},
+						"grafana_alert": {
+							"title": "InstanceDown",
+							"condition": "condition",
+							"data": [
+								{
+									"refId": "query",
+									"datasourceUid": "my-datasource",
+									"relativeTimeRange": {"from": 600, "to": 0},
+									"model": {"refId": "query", "expr": "up < 1", "instant": true, "range": false}
+								},
+								{
+									"refId": "condition",
+									"datasourceUid": "__expr__",
+									"relativeTimeRange": {"from": 0, "to": 0},
+									"model": {"refId": "condition", "type": "math", "expression": "is_number($query) || is_nan($query) || is_inf($query)"}
+								}
+							],
+							"no_data_state": "OK",
+							"exec_err_state": "Error"
+						}
+					}
+				]
+			},
+			{
+				"name": "my-recording-group",
+				"interval": "1m",
+				"rules": [
+					{
+						"grafana_alert": {
+							"title": "job:up:sum",
+							"data": [
+								{
+									"refId": "query",
+									"datasourceUid": "my-datasource",
+									"relativeTimeRange": {"from": 600, "to": 0},
+									"model": {"refId": "query", "expr": "sum by(job) (up)", "instant": true, "range": false}
+								}
+							],
+							"record": {"metric": "job:up:sum", "from": "query"}
+						}
+					}
+				]
+			}
+		]
+	}`, string(exported))
+	assert.Contains(t, string(exported), `"up < 1"`, "expressions are not HTML escaped")
+}
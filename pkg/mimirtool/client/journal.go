@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// journal records the rule groups uploaded by a bulk load, so that a bulk load
+// interrupted, for example by a crash, can be resumed without uploading them again.
+// The journal is a file with the ID of an uploaded rule group per line.
+type journal struct {
+	path      string
+	f         *os.File
+	completed map[string]struct{}
+}
+
+// openJournal opens the journal configured by JournalPath, reading the rule groups
+// already uploaded. It returns a nil journal, which records nothing, if no journal is
+// configured.
+func (r *MimirClient) openJournal() (*journal, error) {
+	if r.journalPath == "" {
+		return nil, nil
+	}
+
+	f, err := os.OpenFile(r.journalPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open journal")
+	}
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "unable to read journal")
+	}
+
+	// A crash while writing can leave a partial last line, which doesn't match any rule
+	// group. It's terminated so that it isn't merged with the next record.
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		if _, err := f.WriteString("\n"); err != nil {
+			f.Close()
+			return nil, errors.Wrap(err, "unable to write journal")
+		}
+	}
+
+	completed := map[string]struct{}{}
+	for _, id := range strings.Split(string(content), "\n") {
+		if id = strings.TrimSpace(id); id != "" {
+			completed[id] = struct{}{}
+		}
+	}
+
+	return &journal{path: r.journalPath, f: f, completed: completed}, nil
+}
+
+// journalID returns the ID of a rule group in the journal.
+func journalID(tenantID, namespace, group string) string {
+	return tenantID + "/" + namespace + "/" + group
+}
+
+// done returns whether the rule group has been uploaded by a previous bulk load.
+func (j *journal) done(id string) bool {
+	if j == nil {
+		return false
+	}
+	_, ok := j.completed[id]
+	return ok
+}
+
+// record records that the rule group has been uploaded. The journal is synced to disk
+// before returning.
+func (j *journal) record(id string) error {
+	if j == nil {
+		return nil
+	}
+	if _, err := j.f.WriteString(id + "\n"); err != nil {
+		return errors.Wrap(err, "unable to write journal")
+	}
+	j.completed[id] = struct{}{}
+	return errors.Wrap(j.f.Sync(), "unable to sync journal")
+}
+
+// close closes the journal. If the bulk load completed, the journal is removed, so
+// that the next bulk load uploads all the rule groups again.
+func (j *journal) close(completed bool) error {
+	if j == nil {
+		return nil
+	}
+	if err := j.f.Close(); err != nil {
+		return errors.Wrap(err, "unable to close journal")
+	}
+	if completed {
+		return errors.Wrap(os.Remove(j.path), "unable to remove journal")
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRuleGroupsFromTar_Journal(t *testing.T) {
+	var (
+		uploadsMtx sync.Mutex
+		uploads    []string
+		failing    = map[string]bool{}
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploadsMtx.Lock()
+		defer uploadsMtx.Unlock()
+		uploads = append(uploads, r.URL.Path)
+
+		if failing[r.URL.Path] {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	journalPath := filepath.Join(t.TempDir(), "journal")
+	client, err := New(Config{Address: ts.URL, ID: "my-id", JournalPath: journalPath})
+	require.NoError(t, err)
+
+	archive := func() *bytes.Buffer {
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		for _, name := range []string{"namespace-1.yaml", "namespace-2.yaml", "namespace-3.yaml"} {
+			content := "groups:\n  - name: group\n    rules:\n      - record: metric:sum\n        expr: sum(metric)\n"
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+			_, err := tw.Write([]byte(content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		return buf
+	}
+
+	// Simulate a crash after namespace-1 has been uploaded, while the record of
+	// namespace-2 was being written.
+	require.NoError(t, os.WriteFile(journalPath, []byte("my-id/namespace-1/group\nmy-id/name"), 0644))
+
+	// The bulk load is resumed, but namespace-3 fails to upload.
+	failing["/api/v1/rules/namespace-3"] = true
+	require.Error(t, LoadRuleGroupsFromTar(context.Background(), client, archive(), nil))
+	assert.Equal(t, []string{"/api/v1/rules/namespace-2", "/api/v1/rules/namespace-3"}, uploads)
+
+	content, err := os.ReadFile(journalPath)
+	require.NoError(t, err)
+	assert.Equal(t, "my-id/namespace-1/group\nmy-id/name\nmy-id/namespace-2/group\n", string(content))
+
+	// Once resumed again, only the remaining rule group is uploaded and, since the bulk
+	// load completes, the journal is removed.
+	uploads = nil
+	failing = map[string]bool{}
+	require.NoError(t, LoadRuleGroupsFromTar(context.Background(), client, archive(), nil))
+	assert.Equal(t, []string{"/api/v1/rules/namespace-3"}, uploads)
+	assert.NoFileExists(t, journalPath)
+
+	// The next bulk load uploads all the rule groups.
+	uploads = nil
+	require.NoError(t, LoadRuleGroupsFromTar(context.Background(), client, archive(), nil))
+	assert.Len(t, uploads, 3)
+}
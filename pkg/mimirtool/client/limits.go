@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configAPIPath        = "/config"
+	runtimeConfigAPIPath = "/runtime_config"
+)
+
+// ErrLimitsUnavailable is returned by GetLimits when the server doesn't expose its
+// configuration, for example because the endpoint is not routed by the gateway.
+var ErrLimitsUnavailable = errors.New("the limits of the tenant are not available")
+
+// TenantLimits holds the ruler limits of a tenant. 0 means unlimited.
+type TenantLimits struct {
+	MaxRulesPerRuleGroup   int `yaml:"ruler_max_rules_per_rule_group"`
+	MaxRuleGroupsPerTenant int `yaml:"ruler_max_rule_groups_per_tenant"`
+}
+
+type configResponse struct {
+	Limits TenantLimits `yaml:"limits"`
+}
+
+type runtimeConfigResponse struct {
+	Overrides map[string]yaml.Node `yaml:"overrides"`
+}
+
+// GetLimits returns the ruler limits of the tenant, for example to validate rule groups
+// before uploading them. The limits are the defaults of the server configuration, with
+// the overrides of the tenant from its runtime configuration applied, if any. If the
+// server configuration is not available, ErrLimitsUnavailable is returned along with
+// empty, therefore unlimited, limits. If only the runtime configuration is not available,
+// the default limits are returned.
+func (r *MimirClient) GetLimits(ctx context.Context) (TenantLimits, error) {
+	cfg := configResponse{}
+	if err := r.getYAML(ctx, configAPIPath, &cfg); err != nil {
+		var statusErr *statusError
+		if errors.Is(err, ErrResourceNotFound) || (errors.As(err, &statusErr) && (statusErr.statusCode == http.StatusUnauthorized || statusErr.statusCode == http.StatusForbidden)) {
+			return TenantLimits{}, ErrLimitsUnavailable
+		}
+		return TenantLimits{}, errors.Wrap(err, "unable to get the server configuration")
+	}
+	limits := cfg.Limits
+
+	runtimeCfg := runtimeConfigResponse{}
+	if err := r.getYAML(ctx, runtimeConfigAPIPath, &runtimeCfg); err != nil {
+		r.logEntry(ctx).WithError(err).Debugln("runtime configuration not available, using the default limits")
+		return limits, nil
+	}
+
+	// The overrides are decoded over the defaults, so that the limits not overridden keep
+	// their default value.
+	if overrides, ok := runtimeCfg.Overrides[r.requestTenantID(ctx)]; ok {
+		if err := overrides.Decode(&limits); err != nil {
+			return TenantLimits{}, errors.Wrap(err, "unable to decode the overrides of the tenant")
+		}
+	}
+
+	return limits, nil
+}
+
+// getYAML fetches the YAML document at path and decodes it into v.
+func (r *MimirClient) getYAML(ctx context.Context, path string, v interface{}) error {
+	res, err := r.doRequest(ctx, path, "GET", nil)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(body, v); err != nil {
+		r.logger.WithFields(log.Fields{
+			"body": string(body),
+		}).Debugln("failed to unmarshal yaml from response")
+
+		return errors.Wrap(err, "unable to unmarshal response")
+	}
+	return nil
+}
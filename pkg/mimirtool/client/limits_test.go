@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMimirClient_GetLimits(t *testing.T) {
+	const config = `
+target: all
+limits:
+  ingestion_rate: 10000
+  ruler_max_rules_per_rule_group: 20
+  ruler_max_rule_groups_per_tenant: 70
+`
+
+	tests := map[string]struct {
+		tenantID       string
+		config         string
+		runtimeConfig  string
+		expectedLimits TenantLimits
+		expectedErr    error
+	}{
+		"overridden limits": {
+			tenantID: "my-id",
+			config:   config,
+			runtimeConfig: `
+overrides:
+  my-id:
+    ingestion_rate: 20000
+    ruler_max_rule_groups_per_tenant: 100
+  other-id:
+    ruler_max_rules_per_rule_group: 5
+`,
+			expectedLimits: TenantLimits{MaxRulesPerRuleGroup: 20, MaxRuleGroupsPerTenant: 100},
+		},
+		"limits not overridden": {
+			tenantID:       "another-id",
+			config:         config,
+			runtimeConfig:  "overrides:\n  my-id:\n    ruler_max_rule_groups_per_tenant: 100\n",
+			expectedLimits: TenantLimits{MaxRulesPerRuleGroup: 20, MaxRuleGroupsPerTenant: 70},
+		},
+		"runtime config not available": {
+			tenantID:       "my-id",
+			config:         config,
+			runtimeConfig:  "runtime config file doesn't exist",
+			expectedLimits: TenantLimits{MaxRulesPerRuleGroup: 20, MaxRuleGroupsPerTenant: 70},
+		},
+		"config not available": {
+			tenantID:    "my-id",
+			expectedErr: ErrLimitsUnavailable,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/config" && testData.config != "":
+					fmt.Fprint(w, testData.config)
+				case r.URL.Path == "/runtime_config" && testData.runtimeConfig != "":
+					fmt.Fprint(w, testData.runtimeConfig)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer ts.Close()
+
+			client, err := New(Config{Address: ts.URL, ID: testData.tenantID})
+			require.NoError(t, err)
+
+			limits, err := client.GetLimits(context.Background())
+			require.ErrorIs(t, err, testData.expectedErr)
+			assert.Equal(t, testData.expectedLimits, limits)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// parseCertFingerprint parses a SHA-256 certificate fingerprint, hex encoded, optionally
+// with the bytes separated by colons as printed by openssl.
+func parseCertFingerprint(s string) ([]byte, error) {
+	fingerprint, err := hex.DecodeString(strings.ReplaceAll(s, ":", ""))
+	if err != nil || len(fingerprint) != sha256.Size {
+		return nil, fmt.Errorf("invalid SHA-256 certificate fingerprint %q", s)
+	}
+	return fingerprint, nil
+}
+
+// verifyPinnedCert returns a function, to be used as tls.Config.VerifyPeerCertificate,
+// failing the handshake if the SHA-256 fingerprint of the leaf certificate presented by
+// the server is not the pinned one.
+func verifyPinnedCert(pinned []byte) func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("the server presented no certificate")
+		}
+
+		fingerprint := sha256.Sum256(rawCerts[0])
+		if !bytes.Equal(fingerprint[:], pinned) {
+			return fmt.Errorf("the SHA-256 fingerprint %x of the server certificate doesn't match the pinned one", fingerprint)
+		}
+		return nil
+	}
+}
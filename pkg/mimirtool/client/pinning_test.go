@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/dskit/crypto/tls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMimirClient_PinnedCertSHA256(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	fingerprint := sha256.Sum256(ts.Certificate().Raw)
+	otherFingerprint := sha256.Sum256([]byte("another certificate"))
+
+	tests := map[string]struct {
+		pin         string
+		expectedErr string
+	}{
+		"matching pin": {
+			pin: hex.EncodeToString(fingerprint[:]),
+		},
+		"matching pin with colons": {
+			pin: strings.ToUpper(colonSeparated(hex.EncodeToString(fingerprint[:]))),
+		},
+		"mismatching pin": {
+			pin:         hex.EncodeToString(otherFingerprint[:]),
+			expectedErr: "doesn't match the pinned one",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			client, err := New(Config{
+				Address:          ts.URL,
+				ID:               "my-id",
+				TLS:              tls.ClientConfig{InsecureSkipVerify: true},
+				PinnedCertSHA256: testData.pin,
+			})
+			require.NoError(t, err)
+
+			err = client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group")
+			if testData.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testData.expectedErr)
+		})
+	}
+
+	_, err := New(Config{Address: ts.URL, ID: "my-id", PinnedCertSHA256: "not-a-fingerprint"})
+	require.EqualError(t, err, `invalid SHA-256 certificate fingerprint "not-a-fingerprint"`)
+}
+
+func colonSeparated(s string) string {
+	var parts []string
+	for i := 0; i < len(s); i += 2 {
+		parts = append(parts, s[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,16 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+// ProgressFunc is called by the bulk operations after each item is processed, with the
+// number of items processed so far and the total number of items. The total is negative
+// when it's not known upfront, because the items are streamed. The bulk operations call
+// it from their own goroutine, before returning, and accept a nil ProgressFunc.
+type ProgressFunc func(done, total int)
+
+// report calls fn, if set.
+func (fn ProgressFunc) report(done, total int) {
+	if fn != nil {
+		fn(done, total)
+	}
+}
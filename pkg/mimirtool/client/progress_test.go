@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressFunc(t *testing.T) {
+	const numItems = 20
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s:\n  - name: group\n    rules:\n      - record: metric:sum\n        expr: sum(metric)\n", r.Header.Get("X-Scope-OrgID"))
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	type call struct{ done, total int }
+	assertCalls := func(t *testing.T, calls []call, expectedTotal int) {
+		require.Len(t, calls, numItems)
+		for i, c := range calls {
+			assert.Equal(t, call{done: i + 1, total: expectedTotal}, c)
+		}
+	}
+
+	t.Run("ListRulesForTenants", func(t *testing.T) {
+		tenantIDs := make([]string, 0, numItems)
+		for i := 0; i < numItems; i++ {
+			tenantIDs = append(tenantIDs, fmt.Sprintf("tenant-%02d", i))
+		}
+
+		var calls []call
+		_, err := client.ListRulesForTenants(context.Background(), tenantIDs, func(done, total int) {
+			calls = append(calls, call{done, total})
+		})
+		require.NoError(t, err)
+		assertCalls(t, calls, numItems)
+	})
+
+	t.Run("LoadRuleGroupsFromTar", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		tw := tar.NewWriter(&buf)
+		for i := 0; i < numItems; i++ {
+			content := fmt.Sprintf("groups:\n  - name: group-%02d\n    rules:\n      - record: metric:sum\n        expr: sum(metric)\n", i)
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: fmt.Sprintf("namespace-%02d.yaml", i), Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+			_, err := tw.Write([]byte(content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+
+		// The archive is streamed, so the total is unknown.
+		var calls []call
+		require.NoError(t, LoadRuleGroupsFromTar(context.Background(), client, &buf, func(done, total int) {
+			calls = append(calls, call{done, total})
+		}))
+		assertCalls(t, calls, -1)
+	})
+}
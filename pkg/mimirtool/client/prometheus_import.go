@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+const (
+	prometheusRulesAPIPath = "/api/v1/rules"
+
+	// defaultPrometheusTimeout is the timeout of the requests to Prometheus, when no
+	// HTTP client is given to ImportFromPrometheus.
+	defaultPrometheusTimeout = 30 * time.Second
+)
+
+// ImportFromPrometheus fetches the rule groups loaded by the Prometheus server at promURL
+// and uploads them to the given namespace. Both alerting and recording rules are imported.
+// Since all the rule groups are uploaded to the same namespace, their names must be unique.
+// Rule groups failing to upload are reported in a MultiError, by rule group name.
+//
+// The rules are fetched with promClient, whose transport can be configured for the TLS
+// and the authentication the Prometheus server requires. If nil, a client with the
+// default transport and a timeout of 30s is used. If set, onProgress is called after
+// each rule group. The rule groups recorded in the journal configured by
+// Config.JournalPath are skipped.
+func ImportFromPrometheus(ctx context.Context, promClient *http.Client, promURL string, client *MimirClient, namespace string, onProgress ProgressFunc) error {
+	if promClient == nil {
+		promClient = &http.Client{Timeout: defaultPrometheusTimeout}
+	}
+
+	statuses, err := fetchPrometheusRuleGroups(ctx, client.logEntry(ctx), promClient, promURL)
+	if err != nil {
+		return errors.Wrap(err, "unable to fetch rules from Prometheus")
+	}
+
+	groups := make([]rwrulefmt.RuleGroup, 0, len(statuses))
+	names := make(map[string]struct{}, len(statuses))
+	for _, status := range statuses {
+		if _, ok := names[status.Name]; ok {
+			return fmt.Errorf("rule group %q is defined more than once in Prometheus", status.Name)
+		}
+		names[status.Name] = struct{}{}
+
+		group, err := ruleGroupFromStatus(status)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, group)
+	}
+
+	j, err := client.openJournal()
+	if err != nil {
+		return err
+	}
+
+	err = importRuleGroups(ctx, client, j, namespace, groups, onProgress)
+	if closeErr := j.close(err == nil); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func importRuleGroups(ctx context.Context, client *MimirClient, j *journal, namespace string, groups []rwrulefmt.RuleGroup, onProgress ProgressFunc) error {
+	errs := &MultiError{}
+	for i, group := range groups {
+		id := journalID(client.requestTenantID(ctx), namespace, group.Name)
+		if j.done(id) {
+			client.logger.WithField("group", group.Name).Debugln("skipping rule group recorded in the journal")
+		} else if err := client.CreateRuleGroup(ctx, namespace, group); err != nil {
+			errs.Add(group.Name, errors.Wrap(err, "unable to import rule group"))
+			if client.stopOnFirstError {
+				return errs.Err()
+			}
+		} else if err := j.record(id); err != nil {
+			return err
+		}
+		onProgress.report(i+1, len(groups))
+	}
+
+	return errs.Err()
+}
+
+func fetchPrometheusRuleGroups(ctx context.Context, logger *log.Entry, promClient *http.Client, promURL string) ([]RuleGroupStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(promURL, "/")+prometheusRulesAPIPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := promClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if err := checkResponse(logger, res); err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := ruleStatusesResponse{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal response")
+	}
+
+	return resp.Data.Groups, nil
+}
+
+// ruleGroupFromStatus converts a rule group, as reported by the Prometheus rules API, to
+// its configuration.
+func ruleGroupFromStatus(status RuleGroupStatus) (rwrulefmt.RuleGroup, error) {
+	group := rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{
+		Name:     status.Name,
+		Interval: secondsToDuration(status.Interval),
+	}}
+
+	for _, rule := range status.Rules {
+		node := rulefmt.RuleNode{
+			Expr:   yaml.Node{Kind: yaml.ScalarNode, Value: rule.Query},
+			Labels: rule.Labels,
+		}
+
+		switch rule.Type {
+		case "alerting":
+			node.Alert = yaml.Node{Kind: yaml.ScalarNode, Value: rule.Name}
+			node.For = secondsToDuration(rule.Duration)
+			node.Annotations = rule.Annotations
+		case "recording":
+			node.Record = yaml.Node{Kind: yaml.ScalarNode, Value: rule.Name}
+		default:
+			return rwrulefmt.RuleGroup{}, fmt.Errorf("rule %q of rule group %q has unknown type %q", rule.Name, status.Name, rule.Type)
+		}
+
+		group.Rules = append(group.Rules, node)
+	}
+
+	return group, nil
+}
+
+func secondsToDuration(seconds float64) model.Duration {
+	return model.Duration(time.Duration(seconds * float64(time.Second)))
+}
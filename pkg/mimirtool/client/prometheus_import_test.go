@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+func TestImportFromPrometheus(t *testing.T) {
+	prom := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/rules" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"status": "success",
+			"data": {
+				"groups": [
+					{
+						"name": "recording",
+						"file": "/etc/prometheus/recording.yml",
+						"interval": 30,
+						"rules": [
+							{"name": "job:up:sum", "query": "sum by(job) (up)", "labels": {"team": "a"}, "health": "ok", "type": "recording"}
+						]
+					},
+					{
+						"name": "alerting",
+						"file": "/etc/prometheus/alerting.yml",
+						"interval": 60,
+						"rules": [
+							{"state": "inactive", "name": "InstanceDown", "query": "up == 0", "duration": 300, "labels": {"severity": "critical"}, "annotations": {"summary": "Instance is down"}, "alerts": [], "health": "ok", "type": "alerting"}
+						]
+					}
+				]
+			}
+		}`)
+	}))
+	defer prom.Close()
+
+	var (
+		uploadsMtx sync.Mutex
+		uploads    = map[string]rwrulefmt.RuleGroup{}
+	)
+	mimir := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/rules/imported", r.URL.Path)
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		rg := rwrulefmt.RuleGroup{}
+		require.NoError(t, yaml.Unmarshal(body, &rg))
+
+		uploadsMtx.Lock()
+		defer uploadsMtx.Unlock()
+		uploads[rg.Name] = rg
+	}))
+	defer mimir.Close()
+
+	client, err := New(Config{Address: mimir.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	require.NoError(t, ImportFromPrometheus(context.Background(), nil, prom.URL, client, "imported", nil))
+	require.Len(t, uploads, 2)
+
+	recording := uploads["recording"]
+	assert.Equal(t, model.Duration(30*time.Second), recording.Interval)
+	require.Len(t, recording.Rules, 1)
+	assert.Equal(t, "job:up:sum", recording.Rules[0].Record.Value)
+	assert.Empty(t, recording.Rules[0].Alert.Value)
+	assert.Equal(t, "sum by(job) (up)", recording.Rules[0].Expr.Value)
+	assert.Equal(t, map[string]string{"team": "a"}, recording.Rules[0].Labels)
+
+	alerting := uploads["alerting"]
+	assert.Equal(t, model.Duration(time.Minute), alerting.Interval)
+	require.Len(t, alerting.Rules, 1)
+	assert.Equal(t, "InstanceDown", alerting.Rules[0].Alert.Value)
+	assert.Empty(t, alerting.Rules[0].Record.Value)
+	assert.Equal(t, "up == 0", alerting.Rules[0].Expr.Value)
+	assert.Equal(t, model.Duration(5*time.Minute), alerting.Rules[0].For)
+	assert.Equal(t, map[string]string{"severity": "critical"}, alerting.Rules[0].Labels)
+	assert.Equal(t, map[string]string{"summary": "Instance is down"}, alerting.Rules[0].Annotations)
+}
+
+func TestImportFromPrometheus_DuplicateGroupNames(t *testing.T) {
+	prom := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status": "success", "data": {"groups": [
+			{"name": "group", "file": "a.yml", "rules": []},
+			{"name": "group", "file": "b.yml", "rules": []}
+		]}}`)
+	}))
+	defer prom.Close()
+
+	requests := 0
+	mimir := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer mimir.Close()
+
+	client, err := New(Config{Address: mimir.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	err = ImportFromPrometheus(context.Background(), nil, prom.URL, client, "imported", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"group"`)
+	assert.Equal(t, 0, requests)
+}
+
+func TestImportFromPrometheus_HTTPClient(t *testing.T) {
+	mimir := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer mimir.Close()
+
+	client, err := New(Config{Address: mimir.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	t.Run("the given client is used", func(t *testing.T) {
+		prom := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"status": "success", "data": {"groups": [{"name": "group", "file": "a.yml", "rules": []}]}}`)
+		}))
+		defer prom.Close()
+
+		// The test server certificate is only trusted by its own client.
+		require.Error(t, ImportFromPrometheus(context.Background(), nil, prom.URL, client, "imported", nil))
+		require.NoError(t, ImportFromPrometheus(context.Background(), prom.Client(), prom.URL, client, "imported", nil))
+	})
+
+	t.Run("the client timeout is honoured", func(t *testing.T) {
+		unblock := make(chan struct{})
+		prom := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-unblock
+		}))
+		defer prom.Close()
+		defer close(unblock)
+
+		err := ImportFromPrometheus(context.Background(), &http.Client{Timeout: 50 * time.Millisecond}, prom.URL, client, "imported", nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unable to fetch rules from Prometheus")
+	})
+}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const readyPath = "/ready"
+
+// waitReadyBackoff is the backoff between the readiness checks of WaitReady.
+var waitReadyBackoff = retryBackoff{
+	strategy: BackoffExponential,
+	min:      100 * time.Millisecond,
+	max:      5 * time.Second,
+}
+
+// WaitReady waits until the server reports itself ready, or the context is done. The
+// server not accepting connections yet or not being ready is retried with backoff,
+// while authentication and authorization failures are returned immediately.
+func (r *MimirClient) WaitReady(ctx context.Context) error {
+	var delay time.Duration
+
+	for retry := 0; ; retry++ {
+		res, err := r.doRequest(ctx, readyPath, "GET", nil)
+		if err == nil {
+			res.Body.Close()
+			return nil
+		}
+
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && (statusErr.statusCode == http.StatusUnauthorized || statusErr.statusCode == http.StatusForbidden) {
+			return err
+		}
+
+		r.logger.WithError(err).Debugln("server is not ready yet")
+
+		delay = waitReadyBackoff.delay(retry, delay)
+		if sleepErr := r.clock.Sleep(ctx, delay); sleepErr != nil {
+			return errors.Wrapf(sleepErr, "server is not ready: %v", err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestMimirClient_WaitReady(t *testing.T) {
+	// Make the test faster.
+	prevBackoff := waitReadyBackoff
+	waitReadyBackoff = retryBackoff{strategy: BackoffExponential, min: 10 * time.Millisecond, max: 50 * time.Millisecond}
+	t.Cleanup(func() { waitReadyBackoff = prevBackoff })
+
+	t.Run("waits until the server is listening and ready", func(t *testing.T) {
+		// Reserve an address, so that the connections are refused until the server starts.
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := listener.Addr().String()
+		require.NoError(t, listener.Close())
+
+		requests := atomic.NewInt32(0)
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/ready", r.URL.Path)
+			if requests.Inc() < 3 {
+				http.Error(w, "ingester not ready", http.StatusServiceUnavailable)
+			}
+		}))
+		defer ts.Close()
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			listener, err := net.Listen("tcp", addr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			ts.Listener = listener
+			ts.Start()
+		}()
+
+		client, err := New(Config{Address: "http://" + addr, ID: "my-id"})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		require.NoError(t, client.WaitReady(ctx))
+		assert.Equal(t, int32(3), requests.Load())
+	})
+
+	t.Run("fails fast on authentication errors", func(t *testing.T) {
+		requests := atomic.NewInt32(0)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Inc()
+			http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		}))
+		defer ts.Close()
+
+		client, err := New(Config{Address: ts.URL, ID: "my-id", Key: "wrong"})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err = client.WaitReady(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "401")
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("gives up when the context expires", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+
+		client, err := New(Config{Address: ts.URL, ID: "my-id"})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err = client.WaitReady(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Contains(t, err.Error(), "503")
+	})
+}
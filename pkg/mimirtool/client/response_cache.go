@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// ResponseCache stores the responses of the GET requests returned with an ETag, so that
+// they're revalidated with If-None-Match and served from the cache when unchanged.
+// Implementations must be safe for concurrent use.
+type ResponseCache interface {
+	Get(key string) (CachedResponse, bool)
+	Set(key string, resp CachedResponse)
+}
+
+// CachedResponse is a response stored in a ResponseCache.
+type CachedResponse struct {
+	ETag   string
+	Header http.Header
+	Body   []byte
+}
+
+// responseCacheKey returns the key of the response of the GET request to path issued for
+// the tenant.
+func responseCacheKey(tenantID, path string) string {
+	return tenantID + " " + path
+}
+
+// cacheResponse stores the response in the cache if it has an ETag, and replaces its
+// body with the read one.
+func cacheResponse(cache ResponseCache, key string, resp *http.Response) error {
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	cache.Set(key, CachedResponse{ETag: etag, Header: resp.Header.Clone(), Body: body})
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// cachedResponse turns a 304 Not Modified response into the cached one.
+func cachedResponse(resp *http.Response, cached CachedResponse) {
+	resp.Body.Close()
+
+	resp.StatusCode = http.StatusOK
+	resp.Status = "200 OK"
+	resp.Header = cached.Header.Clone()
+	resp.ContentLength = int64(len(cached.Body))
+	resp.Body = io.NopCloser(bytes.NewReader(cached.Body))
+}
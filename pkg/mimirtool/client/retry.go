@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Supported backoff strategies between request retries.
+const (
+	BackoffConstant           = "constant"
+	BackoffExponential        = "exponential"
+	BackoffDecorrelatedJitter = "decorrelated-jitter"
+)
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 10 * time.Second
+)
+
+// retryBackoff computes the delay before each retry of a request.
+type retryBackoff struct {
+	strategy string
+	min, max time.Duration
+}
+
+func newRetryBackoff(strategy string, min, max time.Duration) (retryBackoff, error) {
+	if strategy == "" {
+		strategy = BackoffExponential
+	}
+	if min <= 0 {
+		min = defaultMinBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	if max < min {
+		return retryBackoff{}, fmt.Errorf("the max backoff %s is lower than the min backoff %s", max, min)
+	}
+
+	switch strategy {
+	case BackoffConstant, BackoffExponential, BackoffDecorrelatedJitter:
+		return retryBackoff{strategy: strategy, min: min, max: max}, nil
+	default:
+		return retryBackoff{}, fmt.Errorf("unknown backoff strategy %q", strategy)
+	}
+}
+
+// delay returns the delay before the given retry, starting from 0, given the previous
+// delay. The returned delay is always between the min and max backoff.
+func (b retryBackoff) delay(retry int, prev time.Duration) time.Duration {
+	var d time.Duration
+
+	switch b.strategy {
+	case BackoffConstant:
+		d = b.min
+	case BackoffExponential:
+		d = b.min
+		for i := 0; i < retry && d < b.max; i++ {
+			d *= 2
+		}
+	case BackoffDecorrelatedJitter:
+		// See https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/.
+		upper := 3 * prev
+		if upper <= b.min {
+			upper = b.min + 1
+		}
+		d = b.min + time.Duration(rand.Int63n(int64(upper-b.min)))
+	}
+
+	if d > b.max {
+		d = b.max
+	}
+	if d < b.min {
+		d = b.min
+	}
+	return d
+}
+
+// isRetryable returns whether a failed request can be retried. Only requests which can
+// be safely repeated are retried, when failing with a transport error, a server error
+// or because of rate limiting.
+func (r *MimirClient) isRetryable(ctx context.Context, method string, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodDelete:
+	case http.MethodPost:
+		if !r.useIdempotencyKeys {
+			return r.retryUnsentPOST && isConnectionReset(err)
+		}
+	default:
+		return false
+	}
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode == http.StatusTooManyRequests || statusErr.statusCode >= 500
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// isConnectionReset returns whether the request failed because the connection was reset
+// or closed before any response was received.
+func isConnectionReset(err error) bool {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return false
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF)
+}
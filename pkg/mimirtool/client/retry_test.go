@@ -0,0 +1,278 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestRetryBackoff_Delay(t *testing.T) {
+	const (
+		minBackoff = 100 * time.Millisecond
+		maxBackoff = time.Second
+	)
+
+	delays := func(b retryBackoff, retries int) []time.Duration {
+		var prev time.Duration
+		out := make([]time.Duration, 0, retries)
+		for retry := 0; retry < retries; retry++ {
+			prev = b.delay(retry, prev)
+			out = append(out, prev)
+		}
+		return out
+	}
+
+	t.Run("constant", func(t *testing.T) {
+		b, err := newRetryBackoff(BackoffConstant, minBackoff, maxBackoff)
+		require.NoError(t, err)
+		assert.Equal(t, []time.Duration{minBackoff, minBackoff, minBackoff, minBackoff}, delays(b, 4))
+	})
+
+	t.Run("exponential", func(t *testing.T) {
+		b, err := newRetryBackoff(BackoffExponential, minBackoff, maxBackoff)
+		require.NoError(t, err)
+		assert.Equal(t, []time.Duration{
+			100 * time.Millisecond,
+			200 * time.Millisecond,
+			400 * time.Millisecond,
+			800 * time.Millisecond,
+			time.Second,
+			time.Second,
+		}, delays(b, 6))
+	})
+
+	t.Run("decorrelated jitter", func(t *testing.T) {
+		b, err := newRetryBackoff(BackoffDecorrelatedJitter, minBackoff, maxBackoff)
+		require.NoError(t, err)
+
+		for i := 0; i < 100; i++ {
+			var prev time.Duration
+			for retry, d := range delays(b, 10) {
+				assert.GreaterOrEqual(t, d, minBackoff)
+				assert.LessOrEqual(t, d, maxBackoff)
+				if retry > 0 {
+					assert.LessOrEqual(t, d, 3*prev)
+				}
+				prev = d
+			}
+		}
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		b, err := newRetryBackoff("", 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, retryBackoff{strategy: BackoffExponential, min: defaultMinBackoff, max: defaultMaxBackoff}, b)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := newRetryBackoff("linear", minBackoff, maxBackoff)
+		assert.EqualError(t, err, `unknown backoff strategy "linear"`)
+
+		_, err = newRetryBackoff(BackoffConstant, maxBackoff, minBackoff)
+		assert.Error(t, err)
+	})
+}
+
+func TestMimirClient_Retries(t *testing.T) {
+	tests := map[string]struct {
+		method             string
+		status             int
+		failures           int32
+		maxRetries         int
+		useIdempotencyKeys bool
+		expectedRequests   int32
+		expectedDelays     []time.Duration
+		expectedErr        bool
+	}{
+		"GET failing with a server error is retried": {
+			method:           http.MethodGet,
+			status:           http.StatusServiceUnavailable,
+			failures:         2,
+			maxRetries:       3,
+			expectedRequests: 3,
+			expectedDelays:   []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		"DELETE failing because of rate limiting is retried": {
+			method:           http.MethodDelete,
+			status:           http.StatusTooManyRequests,
+			failures:         1,
+			maxRetries:       3,
+			expectedRequests: 2,
+			expectedDelays:   []time.Duration{100 * time.Millisecond},
+		},
+		"retries are bounded": {
+			method:           http.MethodGet,
+			status:           http.StatusInternalServerError,
+			failures:         10,
+			maxRetries:       2,
+			expectedRequests: 3,
+			expectedDelays:   []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+			expectedErr:      true,
+		},
+		"retries are disabled by default": {
+			method:           http.MethodGet,
+			status:           http.StatusInternalServerError,
+			failures:         1,
+			expectedRequests: 1,
+			expectedErr:      true,
+		},
+		"client errors are not retried": {
+			method:           http.MethodGet,
+			status:           http.StatusBadRequest,
+			failures:         1,
+			maxRetries:       3,
+			expectedRequests: 1,
+			expectedErr:      true,
+		},
+		"POST is not retried without idempotency keys": {
+			method:           http.MethodPost,
+			status:           http.StatusServiceUnavailable,
+			failures:         1,
+			maxRetries:       3,
+			expectedRequests: 1,
+			expectedErr:      true,
+		},
+		"POST is retried with idempotency keys": {
+			method:             http.MethodPost,
+			status:             http.StatusServiceUnavailable,
+			failures:           1,
+			maxRetries:         3,
+			useIdempotencyKeys: true,
+			expectedRequests:   2,
+			expectedDelays:     []time.Duration{100 * time.Millisecond},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			requests := atomic.NewInt32(0)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Inc() <= testData.failures {
+					http.Error(w, "failure", testData.status)
+				}
+			}))
+			defer ts.Close()
+
+			client, err := New(Config{
+				Address:            ts.URL,
+				ID:                 "my-id",
+				MaxRetries:         testData.maxRetries,
+				UseIdempotencyKeys: testData.useIdempotencyKeys,
+			})
+			require.NoError(t, err)
+
+			clk := newFakeClock()
+			client.clock = clk
+
+			_, err = client.doRequest(context.Background(), "/api/v1/rules", testData.method, nil)
+			if testData.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, testData.expectedRequests, requests.Load())
+			assert.Equal(t, testData.expectedDelays, clk.Sleeps())
+		})
+	}
+}
+
+func TestMimirClient_RetriesWithFakeClock(t *testing.T) {
+	requests := atomic.NewInt32(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Inc() <= 5 {
+			http.Error(w, "failure", http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	// The retries would take about 30s with the real clock.
+	client, err := New(Config{Address: ts.URL, ID: "my-id", MaxRetries: 5, BackoffStrategy: BackoffConstant, MinBackoff: 6 * time.Second})
+	require.NoError(t, err)
+
+	clk := newFakeClock()
+	client.clock = clk
+	start := clk.Now()
+
+	_, err = client.doRequest(context.Background(), "/api/v1/rules", http.MethodGet, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(6), requests.Load())
+	assert.Equal(t, 30*time.Second, clk.Now().Sub(start))
+}
+
+func TestMimirClient_RetryUnsentPOST(t *testing.T) {
+	for _, retryUnsentPOST := range []bool{false, true} {
+		t.Run(fmt.Sprintf("retry unsent POST: %t", retryUnsentPOST), func(t *testing.T) {
+			requests := atomic.NewInt32(0)
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Inc()
+			}))
+			listener := &resettingListener{Listener: ts.Listener, resets: atomic.NewInt32(1)}
+			ts.Listener = listener
+			ts.Start()
+			defer ts.Close()
+
+			client, err := New(Config{Address: ts.URL, ID: "my-id", MaxRetries: 3, RetryUnsentPOST: retryUnsentPOST})
+			require.NoError(t, err)
+
+			clk := newFakeClock()
+			client.clock = clk
+
+			_, err = client.doRequest(context.Background(), "/api/v1/rules/my-namespace", http.MethodPost, []byte("name: my-group"))
+			if !retryUnsentPOST {
+				require.Error(t, err)
+				assert.True(t, isConnectionReset(err), "error: %v", err)
+				assert.Equal(t, int32(0), requests.Load())
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, int32(1), requests.Load())
+			assert.Equal(t, []time.Duration{100 * time.Millisecond}, clk.Sleeps())
+		})
+	}
+
+	// A POST failing with a response is still not retried.
+	requests := atomic.NewInt32(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+		http.Error(w, "failure", http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", MaxRetries: 3, RetryUnsentPOST: true})
+	require.NoError(t, err)
+	client.clock = newFakeClock()
+
+	_, err = client.doRequest(context.Background(), "/api/v1/rules/my-namespace", http.MethodPost, []byte("name: my-group"))
+	require.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+// resettingListener resets the first connections it accepts, before reading anything.
+type resettingListener struct {
+	net.Listener
+	resets *atomic.Int32
+}
+
+func (l *resettingListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil || l.resets.Dec() < 0 {
+			return conn, err
+		}
+
+		// Closing the connection with a zero linger sends a RST.
+		_ = conn.(*net.TCPConn).SetLinger(0)
+		_ = conn.Close()
+	}
+}
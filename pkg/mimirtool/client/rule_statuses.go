@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const rulesStatusAPIPath = "/prometheus/api/v1/rules"
+
+// RuleHealth is the health of a rule, as of its last evaluation.
+type RuleHealth string
+
+const (
+	RuleHealthOK      RuleHealth = "ok"
+	RuleHealthErr     RuleHealth = "err"
+	RuleHealthUnknown RuleHealth = "unknown"
+)
+
+// ParseRuleHealth parses a rule health, returning an error if it's not one of the known values.
+func ParseRuleHealth(s string) (RuleHealth, error) {
+	switch h := RuleHealth(s); h {
+	case RuleHealthOK, RuleHealthErr, RuleHealthUnknown:
+		return h, nil
+	default:
+		return h, fmt.Errorf("unknown rule health %q", s)
+	}
+}
+
+// RuleGroupStatus is the evaluation status of a rule group, as reported by
+// the Prometheus-compatible rules API.
+type RuleGroupStatus struct {
+	Name           string       `json:"name"`
+	File           string       `json:"file"`
+	Rules          []RuleStatus `json:"rules"`
+	Interval       float64      `json:"interval"`
+	LastEvaluation time.Time    `json:"lastEvaluation"`
+	EvaluationTime float64      `json:"evaluationTime"`
+}
+
+// RuleStatus is the evaluation status of a single alerting or recording rule.
+type RuleStatus struct {
+	Name           string            `json:"name"`
+	Query          string            `json:"query"`
+	Type           string            `json:"type"`
+	Health         RuleHealth        `json:"health"`
+	Duration       float64           `json:"duration,omitempty"`
+	LastError      string            `json:"lastError,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Annotations    map[string]string `json:"annotations,omitempty"`
+	LastEvaluation time.Time         `json:"lastEvaluation"`
+	EvaluationTime float64           `json:"evaluationTime"`
+}
+
+// FailingRule is a rule whose last evaluation failed.
+type FailingRule struct {
+	Namespace string
+	Group     string
+	Rule      string
+	Error     string
+}
+
+// RuleGroupEvalStat is the cost of the last evaluation of a rule group.
+type RuleGroupEvalStat struct {
+	Namespace      string
+	Group          string
+	Rules          int
+	EvaluationTime time.Duration
+	LastEvaluation time.Time
+}
+
+type ruleStatusesResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Groups []RuleGroupStatus `json:"groups"`
+	} `json:"data"`
+}
+
+// ListRuleStatuses retrieves the evaluation status of all the rule groups of the tenant.
+func (r *MimirClient) ListRuleStatuses(ctx context.Context) ([]RuleGroupStatus, error) {
+	return r.listRuleStatuses(ctx, rulesStatusAPIPath)
+}
+
+// ListRuleStatusesByNamespace retrieves the evaluation status of all the rule groups of
+// the tenant like ListRuleStatuses, keyed by namespace. The rules status API can't be
+// filtered by namespace, so the statuses are fetched with a single request and grouped
+// by their file.
+func (r *MimirClient) ListRuleStatusesByNamespace(ctx context.Context) (map[string][]RuleGroupStatus, error) {
+	groups, err := r.ListRuleStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byNamespace := map[string][]RuleGroupStatus{}
+	for _, group := range groups {
+		byNamespace[group.File] = append(byNamespace[group.File], group)
+	}
+	return byNamespace, nil
+}
+
+func (r *MimirClient) listRuleStatuses(ctx context.Context, path string) ([]RuleGroupStatus, error) {
+	res, err := r.doRequest(ctx, path, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := ruleStatusesResponse{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		r.logger.WithFields(log.Fields{
+			"body": string(body),
+		}).Debugln("failed to unmarshal rule statuses from response")
+
+		return nil, errors.Wrap(err, "unable to unmarshal response")
+	}
+
+	if !r.lenientRuleHealth {
+		for _, group := range resp.Data.Groups {
+			for _, rule := range group.Rules {
+				if _, err := ParseRuleHealth(string(rule.Health)); err != nil {
+					return nil, errors.Wrapf(err, "rule %s of rule group %s", rule.Name, group.Name)
+				}
+			}
+		}
+	}
+
+	return resp.Data.Groups, nil
+}
+
+// ListFailingRules retrieves the rules whose last evaluation failed.
+func (r *MimirClient) ListFailingRules(ctx context.Context) ([]FailingRule, error) {
+	groups, err := r.ListRuleStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var failing []FailingRule
+	for _, group := range groups {
+		for _, rule := range group.Rules {
+			if rule.LastError == "" && rule.Health != RuleHealthErr {
+				continue
+			}
+
+			failing = append(failing, FailingRule{
+				Namespace: group.File,
+				Group:     group.Name,
+				Rule:      rule.Name,
+				Error:     rule.LastError,
+			})
+		}
+	}
+
+	return failing, nil
+}
+
+// ListRulesByEvalTime retrieves the cost of the last evaluation of all the rule groups of
+// the tenant, sorted from the most to the least expensive, for example to find the rule
+// groups to optimize. The rule groups with the same evaluation time are sorted by
+// namespace and name.
+func (r *MimirClient) ListRulesByEvalTime(ctx context.Context) ([]RuleGroupEvalStat, error) {
+	groups, err := r.ListRuleStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]RuleGroupEvalStat, 0, len(groups))
+	for _, group := range groups {
+		stats = append(stats, RuleGroupEvalStat{
+			Namespace:      group.File,
+			Group:          group.Name,
+			Rules:          len(group.Rules),
+			EvaluationTime: time.Duration(group.EvaluationTime * float64(time.Second)),
+			LastEvaluation: group.LastEvaluation,
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].EvaluationTime != stats[j].EvaluationTime {
+			return stats[i].EvaluationTime > stats[j].EvaluationTime
+		}
+		if stats[i].Namespace != stats[j].Namespace {
+			return stats[i].Namespace < stats[j].Namespace
+		}
+		return stats[i].Group < stats[j].Group
+	})
+
+	return stats, nil
+}
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+const ruleStatusesResponseBody = `{
+	"status": "success",
+	"data": {
+		"groups": [
+			{
+				"name": "group-1",
+				"file": "namespace-1",
+				"interval": 60,
+				"evaluationTime": 0.5,
+				"rules": [
+					{"name": "metric:sum", "query": "sum(metric)", "type": "recording", "health": "ok", "evaluationTime": 0.2},
+					{"name": "metric:broken", "query": "sum(metric) / vector(0)", "type": "recording", "health": "err", "lastError": "division by zero", "evaluationTime": 0.3}
+				]
+			},
+			{
+				"name": "group-2",
+				"file": "namespace-2",
+				"interval": 60,
+				"evaluationTime": 0.1,
+				"rules": [
+					{"name": "HighErrorRate", "query": "errors > 10", "type": "alerting", "health": "unknown", "labels": {"severity": "critical"}, "evaluationTime": 0.1}
+				]
+			}
+		]
+	}
+}`
+
+func newRuleStatusesServer(t *testing.T, body string) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/prometheus/api/v1/rules" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestMimirClient_ListRuleStatuses(t *testing.T) {
+	ts := newRuleStatusesServer(t, ruleStatusesResponseBody)
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	groups, err := client.ListRuleStatuses(context.Background())
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+
+	assert.Equal(t, "group-1", groups[0].Name)
+	assert.Equal(t, "namespace-1", groups[0].File)
+	require.Len(t, groups[0].Rules, 2)
+	assert.Equal(t, "division by zero", groups[0].Rules[1].LastError)
+
+	require.Len(t, groups[1].Rules, 1)
+	assert.Equal(t, "alerting", groups[1].Rules[0].Type)
+	assert.Equal(t, map[string]string{"severity": "critical"}, groups[1].Rules[0].Labels)
+}
+
+func TestMimirClient_ListFailingRules(t *testing.T) {
+	ts := newRuleStatusesServer(t, ruleStatusesResponseBody)
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	failing, err := client.ListFailingRules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []FailingRule{{
+		Namespace: "namespace-1",
+		Group:     "group-1",
+		Rule:      "metric:broken",
+		Error:     "division by zero",
+	}}, failing)
+}
+
+func TestMimirClient_ListRulesByEvalTime(t *testing.T) {
+	ts := newRuleStatusesServer(t, `{
+		"status": "success",
+		"data": {
+			"groups": [
+				{"name": "cheap", "file": "namespace-1", "evaluationTime": 0.001, "lastEvaluation": "2022-03-01T10:00:00Z", "rules": [{"name": "a", "health": "ok"}]},
+				{"name": "expensive", "file": "namespace-2", "evaluationTime": 2.5, "lastEvaluation": "2022-03-01T10:00:01Z", "rules": [{"name": "a", "health": "ok"}, {"name": "b", "health": "ok"}]},
+				{"name": "medium-b", "file": "namespace-1", "evaluationTime": 0.25, "lastEvaluation": "2022-03-01T10:00:02Z", "rules": []},
+				{"name": "medium-a", "file": "namespace-1", "evaluationTime": 0.25, "lastEvaluation": "2022-03-01T10:00:03Z", "rules": [{"name": "a", "health": "ok"}]}
+			]
+		}
+	}`)
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	stats, err := client.ListRulesByEvalTime(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []RuleGroupEvalStat{
+		{Namespace: "namespace-2", Group: "expensive", Rules: 2, EvaluationTime: 2500 * time.Millisecond, LastEvaluation: time.Date(2022, 3, 1, 10, 0, 1, 0, time.UTC)},
+		{Namespace: "namespace-1", Group: "medium-a", Rules: 1, EvaluationTime: 250 * time.Millisecond, LastEvaluation: time.Date(2022, 3, 1, 10, 0, 3, 0, time.UTC)},
+		{Namespace: "namespace-1", Group: "medium-b", Rules: 0, EvaluationTime: 250 * time.Millisecond, LastEvaluation: time.Date(2022, 3, 1, 10, 0, 2, 0, time.UTC)},
+		{Namespace: "namespace-1", Group: "cheap", Rules: 1, EvaluationTime: time.Millisecond, LastEvaluation: time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)},
+	}, stats)
+}
+
+func TestMimirClient_ListRuleStatusesHealth(t *testing.T) {
+	const bodyTemplate = `{"status": "success", "data": {"groups": [
+		{"name": "group", "file": "namespace", "rules": [{"name": "rule", "query": "up", "type": "recording", "health": %q}]}
+	]}}`
+
+	tests := map[string]struct {
+		health         string
+		lenient        bool
+		expectedHealth RuleHealth
+		expectedErr    string
+	}{
+		"ok":                      {health: "ok", expectedHealth: RuleHealthOK},
+		"err":                     {health: "err", expectedHealth: RuleHealthErr},
+		"unknown":                 {health: "unknown", expectedHealth: RuleHealthUnknown},
+		"unknown value":           {health: "degraded", expectedErr: `unknown rule health "degraded"`},
+		"unknown value, lenient":  {health: "degraded", lenient: true, expectedHealth: RuleHealth("degraded")},
+		"known value, lenient":    {health: "ok", lenient: true, expectedHealth: RuleHealthOK},
+		"empty value":             {health: "", expectedErr: `unknown rule health ""`},
+		"different case of value": {health: "OK", expectedErr: `unknown rule health "OK"`},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ts := newRuleStatusesServer(t, fmt.Sprintf(bodyTemplate, testData.health))
+
+			client, err := New(Config{Address: ts.URL, ID: "my-id", LenientRuleHealth: testData.lenient})
+			require.NoError(t, err)
+
+			groups, err := client.ListRuleStatuses(context.Background())
+			if testData.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, groups, 1)
+			require.Len(t, groups[0].Rules, 1)
+			assert.Equal(t, testData.expectedHealth, groups[0].Rules[0].Health)
+		})
+	}
+}
+
+func TestMimirClient_ListRuleStatusesByNamespace(t *testing.T) {
+	requests := atomic.NewInt32(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status": "success", "data": {"groups": [
+			{"name": "group-1", "file": "namespace-1", "rules": []},
+			{"name": "group-2", "file": "namespace-2", "rules": []},
+			{"name": "group-3", "file": "namespace-1", "rules": []}
+		]}}`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	byNamespace, err := client.ListRuleStatusesByNamespace(context.Background())
+	require.NoError(t, err)
+	require.Len(t, byNamespace, 2)
+
+	require.Len(t, byNamespace["namespace-1"], 2)
+	assert.Equal(t, "group-1", byNamespace["namespace-1"][0].Name)
+	assert.Equal(t, "group-3", byNamespace["namespace-1"][1].Name)
+	require.Len(t, byNamespace["namespace-2"], 1)
+	assert.Equal(t, "group-2", byNamespace["namespace-2"][0].Name)
+
+	// The statuses are fetched with a single request.
+	assert.Equal(t, int32(1), requests.Load())
+}
@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const rulerAlertsAPIPath = "/prometheus/api/v1/alerts"
+
+// Alert is an active alert of an alerting rule, as reported by the Prometheus-compatible
+// alerts API of the ruler.
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	// State is either pending or firing.
+	State    string     `json:"state"`
+	ActiveAt *time.Time `json:"activeAt,omitempty"`
+	Value    string     `json:"value"`
+}
+
+type alertsResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Alerts []Alert `json:"alerts"`
+	} `json:"data"`
+}
+
+// ListAlerts retrieves the active alerts of the alerting rules of the tenant, for example
+// to verify the rules once uploaded. The alerts are the ones of the ruler, regardless of
+// whether they've been sent to the alertmanager.
+func (r *MimirClient) ListAlerts(ctx context.Context) ([]Alert, error) {
+	res, err := r.doRequest(ctx, rulerAlertsAPIPath, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := alertsResponse{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		r.logger.WithFields(log.Fields{
+			"body": string(body),
+		}).Debugln("failed to unmarshal alerts from response")
+
+		return nil, errors.Wrap(err, "unable to unmarshal response")
+	}
+
+	return resp.Data.Alerts, nil
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMimirClient_ListAlerts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/prometheus/api/v1/alerts" || r.Header.Get("X-Scope-OrgID") != "my-id" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"status": "success",
+			"data": {
+				"alerts": [
+					{
+						"labels": {"alertname": "HighErrorRate", "severity": "critical"},
+						"annotations": {"summary": "High error rate"},
+						"state": "firing",
+						"activeAt": "2022-03-01T10:00:00Z",
+						"value": "1.5e+01"
+					},
+					{
+						"labels": {"alertname": "InstanceDown"},
+						"annotations": {},
+						"state": "pending",
+						"activeAt": "2022-03-01T10:05:00.5Z",
+						"value": "0e+00"
+					}
+				]
+			}
+		}`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	alerts, err := client.ListAlerts(context.Background())
+	require.NoError(t, err)
+
+	firingSince := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	pendingSince := time.Date(2022, 3, 1, 10, 5, 0, int(500*time.Millisecond), time.UTC)
+	assert.Equal(t, []Alert{
+		{
+			Labels:      map[string]string{"alertname": "HighErrorRate", "severity": "critical"},
+			Annotations: map[string]string{"summary": "High error rate"},
+			State:       "firing",
+			ActiveAt:    &firingSince,
+			Value:       "1.5e+01",
+		},
+		{
+			Labels:      map[string]string{"alertname": "InstanceDown"},
+			Annotations: map[string]string{},
+			State:       "pending",
+			ActiveAt:    &pendingSince,
+			Value:       "0e+00",
+		},
+	}, alerts)
+}
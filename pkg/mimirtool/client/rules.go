@@ -7,44 +7,399 @@ package client
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 
+	"github.com/grafana/mimir/pkg/mimirtool/rules"
 	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
 )
 
+// Supported APIs to read the rules from.
+const (
+	RulesReadAPIConfig     = "config"
+	RulesReadAPIPrometheus = "prometheus"
+)
+
+// rulesAPIPath returns the path of the rules API. When the API version auto-detection
+// is enabled, the path is detected from the server build info on the first call and
+// then kept for the lifetime of the client.
+func (r *MimirClient) rulesAPIPath(ctx context.Context) string {
+	if !r.autoDetectAPIVersion {
+		return r.apiPath
+	}
+
+	r.apiPathMtx.Lock()
+	defer r.apiPathMtx.Unlock()
+
+	if !r.apiPathDetected {
+		r.apiPathDetected = true
+
+		info, err := r.BuildInfo(ctx)
+		if err != nil {
+			r.logger.WithError(err).WithField("path", r.apiPath).Warnln("unable to detect the rules API version, falling back to the configured one")
+		} else {
+			r.apiPath = rulesAPIPathFor(info)
+			r.logger.WithField("path", r.apiPath).Debugln("detected the rules API version")
+		}
+	}
+
+	return r.apiPath
+}
+
+// rulesAPIPathFor returns the rules API path served by a server with the given
+// build info. Only Grafana Mimir reports the ruler_config_api feature, while
+// older servers are expected to serve the legacy routes.
+func rulesAPIPathFor(info BuildInfo) string {
+	if _, ok := info.Features["ruler_config_api"]; ok {
+		return rulerAPIPath
+	}
+	return legacyAPIPath
+}
+
+// validateRuleGroup checks the rule group before sending it to the server.
+func (r *MimirClient) validateRuleGroup(rg rwrulefmt.RuleGroup) error {
+	if rg.Name == "" {
+		return ErrEmptyRuleGroupName
+	}
+
+	if r.maxRulesPerGroup > 0 && len(rg.Rules) > r.maxRulesPerGroup {
+		return fmt.Errorf("rule group %q has %d rules, exceeding the limit of %d rules per group", rg.Name, len(rg.Rules), r.maxRulesPerGroup)
+	}
+
+	if interval := time.Duration(rg.Interval); interval != 0 && interval < r.minEvaluationInterval {
+		return fmt.Errorf("rule group %q has an evaluation interval of %s, lower than the minimum of %s", rg.Name, interval, r.minEvaluationInterval)
+	}
+
+	var invalidNames []string
+	for i, rule := range rg.Rules {
+		if rule.Alert.Value == "" && !model.IsValidMetricName(model.LabelValue(rule.Record.Value)) {
+			invalidNames = append(invalidNames, fmt.Sprintf("%q (rule %d)", rule.Record.Value, i))
+		}
+	}
+	if len(invalidNames) > 0 {
+		return fmt.Errorf("rule group %q has recording rules whose record field is not a valid metric name: %s", rg.Name, strings.Join(invalidNames, ", "))
+	}
+
+	var invalid []string
+	for _, rule := range rg.Rules {
+		if rule.Alert.Value == "" {
+			continue
+		}
+
+		var missing []string
+		for _, name := range r.requireLabels {
+			if _, ok := rule.Labels[name]; !ok {
+				missing = append(missing, "label "+name)
+			}
+		}
+		for _, name := range r.requireAnnotations {
+			if _, ok := rule.Annotations[name]; !ok {
+				missing = append(missing, "annotation "+name)
+			}
+		}
+
+		if len(missing) > 0 {
+			invalid = append(invalid, fmt.Sprintf("%s (missing %s)", rule.Alert.Value, strings.Join(missing, ", ")))
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("rule group %q has alerting rules missing required fields: %s", rg.Name, strings.Join(invalid, "; "))
+	}
+
+	return nil
+}
+
+// ruleGroupPollInterval is how often CreateRuleGroupAndWait checks whether a rule
+// group accepted for asynchronous processing has been stored.
+var ruleGroupPollInterval = 500 * time.Millisecond
+
+// withSourceLabels returns a copy of the rule group with the configured source labels
+// added to each rule, unless already set.
+func (r *MimirClient) withSourceLabels(rg rwrulefmt.RuleGroup) rwrulefmt.RuleGroup {
+	if len(r.sourceLabels) == 0 {
+		return rg
+	}
+
+	nodes := make([]rulefmt.RuleNode, 0, len(rg.Rules))
+	for _, rule := range rg.Rules {
+		labels := make(map[string]string, len(rule.Labels)+len(r.sourceLabels))
+		for name, value := range r.sourceLabels {
+			labels[name] = value
+		}
+		for name, value := range rule.Labels {
+			labels[name] = value
+		}
+
+		rule.Labels = labels
+		nodes = append(nodes, rule)
+	}
+	rg.Rules = nodes
+
+	return rg
+}
+
+// prepareRuleGroup returns the rule group as sent to the server.
+func (r *MimirClient) prepareRuleGroup(rg rwrulefmt.RuleGroup) rwrulefmt.RuleGroup {
+	if rg.Interval == 0 && r.defaultEvaluationInterval > 0 {
+		rg.Interval = model.Duration(r.defaultEvaluationInterval)
+	}
+	return r.withSourceLabels(rg)
+}
+
+// ruleGroupPayload returns the rule group as sent to the server, once transformed and
+// validated, and the body of the request creating it.
+func (r *MimirClient) ruleGroupPayload(rg rwrulefmt.RuleGroup) (rwrulefmt.RuleGroup, []byte, error) {
+	if r.ruleTransform != nil {
+		transformed, err := r.ruleTransform(rg)
+		if err != nil {
+			return rwrulefmt.RuleGroup{}, nil, errors.Wrapf(err, "unable to transform rule group %s", rg.Name)
+		}
+		rg = transformed
+	}
+
+	if err := r.validateRuleGroup(rg); err != nil {
+		return rwrulefmt.RuleGroup{}, nil, err
+	}
+
+	rg = r.prepareRuleGroup(rg)
+	payload, err := yaml.Marshal(&rg)
+	if err != nil {
+		return rwrulefmt.RuleGroup{}, nil, err
+	}
+	return rg, payload, nil
+}
+
+// EstimateUploadSize returns the number of bytes of the request bodies sent to create
+// the rule groups, for example to report the progress of a large upload. It returns
+// an error if any of the rule groups would be rejected by CreateRuleGroup.
+func (r *MimirClient) EstimateUploadSize(groups []rwrulefmt.RuleGroup) (int64, error) {
+	var size int64
+	for _, rg := range groups {
+		_, payload, err := r.ruleGroupPayload(rg)
+		if err != nil {
+			return 0, err
+		}
+		size += int64(len(payload))
+	}
+	return size, nil
+}
+
 // CreateRuleGroup creates a new rule group
 func (r *MimirClient) CreateRuleGroup(ctx context.Context, namespace string, rg rwrulefmt.RuleGroup) error {
-	payload, err := yaml.Marshal(&rg)
+	_, err := r.CreateRuleGroupWithResult(ctx, namespace, rg)
+	return err
+}
+
+// CreateResult is the outcome of the creation of a rule group.
+type CreateResult struct {
+	// Unchanged is true if the upload has been skipped, because the rule group stored
+	// by the server already has the same content. It's only set with SkipUnchanged.
+	Unchanged bool
+
+	// Warnings are the non-fatal issues found in the rule group, which is created anyway.
+	Warnings []ValidationWarning
+}
+
+// CreateRuleGroupWithResult creates a new rule group like CreateRuleGroup, and reports
+// whether the upload has been skipped as the rule group is unchanged, along with the
+// validation warnings of the rule group.
+func (r *MimirClient) CreateRuleGroupWithResult(ctx context.Context, namespace string, rg rwrulefmt.RuleGroup) (CreateResult, error) {
+	sent, status, err := r.createRuleGroup(ctx, namespace, rg, CreateOptions{})
 	if err != nil {
+		return CreateResult{}, err
+	}
+	return CreateResult{Unchanged: status == statusUnchanged, Warnings: ruleGroupWarnings(sent)}, nil
+}
+
+// CreateOptions configures how CreateRuleGroupWithOptions creates a rule group.
+type CreateOptions struct {
+	// ValidateOnly asks the server to validate the rule group, including against the
+	// limits of the tenant, without storing it. The validation errors are returned as
+	// for a regular creation.
+	ValidateOnly bool
+}
+
+// CreateRuleGroupWithOptions creates a new rule group like CreateRuleGroup, with the
+// given options.
+func (r *MimirClient) CreateRuleGroupWithOptions(ctx context.Context, namespace string, rg rwrulefmt.RuleGroup, opts CreateOptions) error {
+	_, _, err := r.createRuleGroup(ctx, namespace, rg, opts)
+	return err
+}
+
+// CreateRuleGroupAndWait creates a new rule group like CreateRuleGroup. If the server
+// accepts the rule group for asynchronous processing, it then waits until the rule
+// group is returned by the server with the new content, or the context is done.
+func (r *MimirClient) CreateRuleGroupAndWait(ctx context.Context, namespace string, rg rwrulefmt.RuleGroup) error {
+	sent, status, err := r.createRuleGroup(ctx, namespace, rg, CreateOptions{})
+	if err != nil || status != http.StatusAccepted {
 		return err
 	}
 
+	for {
+		current, err := r.GetRuleGroup(ctx, namespace, rg.Name)
+		if err != nil && !errors.Is(err, ErrResourceNotFound) {
+			return err
+		}
+		if err == nil && rules.CompareGroups(*current, sent) == nil {
+			return nil
+		}
+
+		if err := r.clock.Sleep(ctx, ruleGroupPollInterval); err != nil {
+			return errors.Wrapf(err, "rule group %s has not been stored yet", rg.Name)
+		}
+	}
+}
+
+// statusUnchanged is the status returned by createRuleGroup when the upload has been
+// skipped, as the rule group is unchanged.
+const statusUnchanged = 0
+
+// createRuleGroup creates a new rule group and returns the rule group as sent to the
+// server, along with the status code of the response, or statusUnchanged if the upload
+// has been skipped.
+func (r *MimirClient) createRuleGroup(ctx context.Context, namespace string, rg rwrulefmt.RuleGroup, opts CreateOptions) (rwrulefmt.RuleGroup, int, error) {
+	sent, payload, err := r.ruleGroupPayload(rg)
+	if err != nil {
+		return rwrulefmt.RuleGroup{}, 0, err
+	}
+
+	for _, warning := range ruleGroupWarnings(sent) {
+		r.logEntry(ctx).WithField("namespace", namespace).Warnln(warning.String())
+	}
+
+	if r.skipUnchanged && !opts.ValidateOnly {
+		unchanged, err := r.ruleGroupUnchanged(ctx, namespace, sent)
+		if err != nil {
+			return rwrulefmt.RuleGroup{}, 0, err
+		}
+		if unchanged {
+			r.logEntry(ctx).WithFields(log.Fields{
+				"namespace": namespace,
+				"group":     sent.Name,
+			}).Debugln("rule group unchanged, skipping upload")
+			return sent, statusUnchanged, nil
+		}
+	}
+
 	escapedNamespace := url.PathEscape(namespace)
-	path := r.apiPath + "/" + escapedNamespace
+	path := r.rulesAPIPath(ctx) + "/" + escapedNamespace
+	if opts.ValidateOnly {
+		path += "?validate_only=true"
+	}
 
-	res, err := r.doRequest(path, "POST", payload)
+	res, err := r.doRequest(ctx, path, "POST", payload)
 	if err != nil {
-		return err
+		return rwrulefmt.RuleGroup{}, 0, err
 	}
 
 	res.Body.Close()
 
-	return nil
+	return sent, res.StatusCode, nil
+}
+
+// ruleGroupUnchanged returns whether the rule group stored by the server has the same
+// content as the given one, as sent to the server.
+func (r *MimirClient) ruleGroupUnchanged(ctx context.Context, namespace string, rg rwrulefmt.RuleGroup) (bool, error) {
+	current, err := r.GetRuleGroup(ctx, namespace, rg.Name)
+	if errors.Is(err, ErrResourceNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to get the current rule group %s", rg.Name)
+	}
+
+	currentHash, err := ruleGroupHash(*current)
+	if err != nil {
+		return false, err
+	}
+	hash, err := ruleGroupHash(rg)
+	if err != nil {
+		return false, err
+	}
+	return currentHash == hash, nil
+}
+
+// ruleGroupHash returns a hash of the content of the rule group, which doesn't depend
+// on how the YAML it has been decoded from is formatted, such as the quoting style.
+func ruleGroupHash(rg rwrulefmt.RuleGroup) (string, error) {
+	canonicalNode := func(n yaml.Node) yaml.Node {
+		if n.Value == "" {
+			return yaml.Node{}
+		}
+		return yaml.Node{Kind: yaml.ScalarNode, Value: n.Value}
+	}
+
+	rg.Rules = append([]rulefmt.RuleNode(nil), rg.Rules...)
+	for i := range rg.Rules {
+		rg.Rules[i].Record = canonicalNode(rg.Rules[i].Record)
+		rg.Rules[i].Alert = canonicalNode(rg.Rules[i].Alert)
+		rg.Rules[i].Expr = canonicalNode(rg.Rules[i].Expr)
+	}
+
+	content, err := yaml.Marshal(&rg)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to marshal rule group %s", rg.Name)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
 }
 
 // DeleteRuleGroup creates a new rule group
 func (r *MimirClient) DeleteRuleGroup(ctx context.Context, namespace, groupName string) error {
 	escapedNamespace := url.PathEscape(namespace)
 	escapedGroupName := url.PathEscape(groupName)
-	path := r.apiPath + "/" + escapedNamespace + "/" + escapedGroupName
+	path := r.rulesAPIPath(ctx) + "/" + escapedNamespace + "/" + escapedGroupName
+
+	res, err := r.doRequest(ctx, path, "DELETE", nil)
+	if err != nil {
+		return err
+	}
 
-	res, err := r.doRequest(path, "DELETE", nil)
+	res.Body.Close()
+
+	return nil
+}
+
+// DeleteRuleGroupIfMatch deletes a rule group only if its current ETag, as returned in the
+// headers of GetRuleGroupWithMeta, matches the given one. It returns ErrConflict if the
+// rule group has been modified in the meantime.
+func (r *MimirClient) DeleteRuleGroupIfMatch(ctx context.Context, namespace, groupName, etag string) error {
+	err := r.DeleteRuleGroup(context.WithValue(ctx, ifMatchContextKey, etag), namespace, groupName)
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.statusCode == http.StatusPreconditionFailed {
+		return ErrConflict
+	}
+	return err
+}
+
+// DeleteNamespace deletes all the rule groups of a namespace. When the client requires
+// delete confirmations, confirm must be the name of the namespace, otherwise
+// ErrConfirmationRequired is returned without deleting anything.
+func (r *MimirClient) DeleteNamespace(ctx context.Context, namespace, confirm string) error {
+	if r.requireDeleteConfirmation && confirm != namespace {
+		return ErrConfirmationRequired
+	}
+
+	escapedNamespace := url.PathEscape(namespace)
+	path := r.rulesAPIPath(ctx) + "/" + escapedNamespace
+
+	res, err := r.doRequest(ctx, path, "DELETE", nil)
 	if err != nil {
 		return err
 	}
@@ -54,46 +409,286 @@ func (r *MimirClient) DeleteRuleGroup(ctx context.Context, namespace, groupName
 	return nil
 }
 
+// DrainNamespace deletes the rule groups of a namespace one by one, at most at the rate
+// configured by Config.DrainRate, for example to delete a large namespace without
+// exceeding the rate limits of the server. The deletions rejected by the rate limits are
+// retried with backoff as usual. Rule groups failing to delete are reported in a
+// MultiError, by rule group name. If set, onProgress is called after each rule group.
+func (r *MimirClient) DrainNamespace(ctx context.Context, namespace string, onProgress ProgressFunc) error {
+	ruleSet, err := r.ListRules(ctx, namespace)
+	if errors.Is(err, ErrResourceNotFound) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to list the rule groups of namespace %s", namespace)
+	}
+
+	var interval time.Duration
+	if r.drainRate > 0 {
+		interval = time.Duration(float64(time.Second) / r.drainRate)
+	}
+
+	groups := ruleSet[namespace]
+	errs := &MultiError{}
+	for i, rg := range groups {
+		if i > 0 && interval > 0 {
+			if err := r.clock.Sleep(ctx, interval); err != nil {
+				return err
+			}
+		}
+
+		if err := r.DeleteRuleGroup(ctx, namespace, rg.Name); err != nil {
+			errs.Add(rg.Name, errors.Wrap(err, "unable to delete rule group"))
+			if r.stopOnFirstError {
+				return errs.Err()
+			}
+		}
+		onProgress.report(i+1, len(groups))
+	}
+
+	return errs.Err()
+}
+
+// ReplaceNamespace makes the namespace contain exactly the given rule groups. The rule
+// groups are all validated first, then uploaded, and only once all of them have been
+// uploaded are the other rule groups of the namespace deleted, so that the rules being
+// replaced keep being evaluated until then. The replacement is not atomic server-side:
+// if an upload fails, the rule groups already uploaded are kept, but nothing is deleted.
+// Rule groups failing to delete are reported in a MultiError, by rule group name.
+func (r *MimirClient) ReplaceNamespace(ctx context.Context, namespace string, groups []rwrulefmt.RuleGroup) error {
+	desired := make(map[string]struct{}, len(groups))
+	for _, rg := range groups {
+		if _, ok := desired[rg.Name]; ok {
+			return fmt.Errorf("rule group %q is defined more than once", rg.Name)
+		}
+		desired[rg.Name] = struct{}{}
+
+		if _, _, err := r.ruleGroupPayload(rg); err != nil {
+			return err
+		}
+	}
+
+	current, err := r.ListRules(ctx, namespace)
+	if err != nil && !errors.Is(err, ErrResourceNotFound) {
+		return errors.Wrapf(err, "unable to list the rule groups of namespace %s", namespace)
+	}
+
+	for _, rg := range groups {
+		if err := r.CreateRuleGroup(ctx, namespace, rg); err != nil {
+			return errors.Wrapf(err, "unable to upload rule group %s", rg.Name)
+		}
+	}
+
+	errs := &MultiError{}
+	for _, rg := range current[namespace] {
+		if _, ok := desired[rg.Name]; ok {
+			continue
+		}
+		if err := r.DeleteRuleGroup(ctx, namespace, rg.Name); err != nil {
+			errs.Add(rg.Name, errors.Wrap(err, "unable to delete rule group"))
+			if r.stopOnFirstError {
+				break
+			}
+		}
+	}
+
+	return errs.Err()
+}
+
 // GetRuleGroup retrieves a rule group
 func (r *MimirClient) GetRuleGroup(ctx context.Context, namespace, groupName string) (*rwrulefmt.RuleGroup, error) {
+	rg, _, err := r.GetRuleGroupWithMeta(ctx, namespace, groupName)
+	return rg, err
+}
+
+// GetRuleGroupWithMeta retrieves a rule group, along with the headers of the server response.
+func (r *MimirClient) GetRuleGroupWithMeta(ctx context.Context, namespace, groupName string) (*rwrulefmt.RuleGroup, http.Header, error) {
 	escapedNamespace := url.PathEscape(namespace)
 	escapedGroupName := url.PathEscape(groupName)
-	path := r.apiPath + "/" + escapedNamespace + "/" + escapedGroupName
+	path := r.rulesAPIPath(ctx) + "/" + escapedNamespace + "/" + escapedGroupName
 
-	fmt.Println(path)
-	res, err := r.doRequest(path, "GET", nil)
+	res, err := r.doRequest(ctx, path, "GET", nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	defer res.Body.Close()
 	body, err := io.ReadAll(res.Body)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	rg := rwrulefmt.RuleGroup{}
-	err = yaml.Unmarshal(body, &rg)
+	err = unmarshalWithLocation(body, &rg)
 	if err != nil {
-		log.WithFields(log.Fields{
+		r.logger.WithFields(log.Fields{
 			"body": string(body),
 		}).Debugln("failed to unmarshal rule group from response")
 
-		return nil, errors.Wrap(err, "unable to unmarshal response")
+		return nil, nil, errors.Wrap(err, "unable to unmarshal response")
+	}
+
+	return &rg, res.Header, nil
+}
+
+// yamlErrorLine matches the line reported by the YAML syntax and type errors.
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// unmarshalWithLocation decodes the YAML document like yaml.Unmarshal, but returns a
+// ParseError locating the failure in the document. The syntax errors only report the
+// line, while the type errors also report the column of the first node of the line.
+func unmarshalWithLocation(body []byte, out interface{}) error {
+	root := yaml.Node{}
+	if err := yaml.Unmarshal(body, &root); err != nil {
+		return newParseError(err.Error(), nil)
+	}
+	if root.Kind == 0 {
+		// The document is empty.
+		return nil
+	}
+
+	err := root.Decode(out)
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) && len(typeErr.Errors) > 0 {
+		return newParseError(typeErr.Errors[0], &root)
+	}
+	if err != nil {
+		return &ParseError{Msg: err.Error()}
+	}
+	return nil
+}
+
+// newParseError returns the ParseError of the YAML error message. If set, the column is
+// found from the nodes of root.
+func newParseError(msg string, root *yaml.Node) *ParseError {
+	m := yamlErrorLine.FindStringSubmatch(msg)
+	if m == nil {
+		return &ParseError{Msg: msg}
 	}
 
-	return &rg, nil
+	parseErr := &ParseError{Msg: m[2]}
+	parseErr.Line, _ = strconv.Atoi(m[1])
+	if root != nil {
+		parseErr.Column = firstColumnOfLine(root, parseErr.Line)
+	}
+	return parseErr
+}
+
+// firstColumnOfLine returns the column of the first node of the tree on the line, or 0
+// if there's none.
+func firstColumnOfLine(n *yaml.Node, line int) int {
+	column := 0
+	if n.Line == line && n.Kind != yaml.DocumentNode {
+		column = n.Column
+	}
+	for _, child := range n.Content {
+		if c := firstColumnOfLine(child, line); c > 0 && (column == 0 || c < column) {
+			column = c
+		}
+	}
+	return column
 }
 
 // ListRules retrieves a rule group
 func (r *MimirClient) ListRules(ctx context.Context, namespace string) (map[string][]rwrulefmt.RuleGroup, error) {
-	path := r.apiPath
+	if r.rulesReadAPI == RulesReadAPIPrometheus {
+		return r.listRulesFromStatuses(ctx, namespace)
+	}
+
+	path := r.rulesAPIPath(ctx)
 	if namespace != "" {
 		path = path + "/" + namespace
 	}
 
-	res, err := r.doRequest(path, "GET", nil)
+	return r.listRules(ctx, path)
+}
+
+// ListOptions restricts the rule groups returned by ListRulesFiltered.
+type ListOptions struct {
+	// File, when set, only returns the rule groups of this namespace file.
+	File string
+	// RuleGroup, when set, only returns the rule groups with this name.
+	RuleGroup string
+	// ShardCount, when set, splits the rule groups in this number of disjoint shards, by
+	// hash of their namespace and name, and only returns the ones of the shard ShardIndex.
+	// Listing each of the shards returns all the rule groups, for example to export them
+	// with several clients in parallel. The shards are computed by the client only, so
+	// that they don't depend on how a server would shard the rule groups.
+	ShardCount int
+	ShardIndex int
+}
+
+// matches returns whether the rule group of the namespace matches the options.
+func (o ListOptions) matches(namespace, group string) bool {
+	if o.File != "" && namespace != o.File {
+		return false
+	}
+	if o.RuleGroup != "" && group != o.RuleGroup {
+		return false
+	}
+	if o.ShardCount > 0 {
+		return int(ruleGroupToken(namespace, group)%uint32(o.ShardCount)) == o.ShardIndex
+	}
+	return true
+}
+
+// ListRulesFiltered retrieves the rule groups matching the options. The File and
+// RuleGroup filters are sent to the server as the file and rule_group query parameters,
+// and applied again to the response in case the server doesn't support them. The shard
+// is only applied to the response: it's not sent to the server, as a server sharding
+// with another hash would drop rule groups from the shards.
+func (r *MimirClient) ListRulesFiltered(ctx context.Context, opts ListOptions) (map[string][]rwrulefmt.RuleGroup, error) {
+	if opts.ShardCount < 0 || (opts.ShardCount > 0 && (opts.ShardIndex < 0 || opts.ShardIndex >= opts.ShardCount)) {
+		return nil, fmt.Errorf("invalid shard %d of %d", opts.ShardIndex, opts.ShardCount)
+	}
+
+	var (
+		ruleSet map[string][]rwrulefmt.RuleGroup
+		err     error
+	)
+	if r.rulesReadAPI == RulesReadAPIPrometheus {
+		ruleSet, err = r.listRulesFromStatuses(ctx, "")
+	} else {
+		query := url.Values{}
+		if opts.File != "" {
+			query.Set("file", opts.File)
+		}
+		if opts.RuleGroup != "" {
+			query.Set("rule_group", opts.RuleGroup)
+		}
+
+		path := r.rulesAPIPath(ctx)
+		if len(query) > 0 {
+			path = path + "?" + query.Encode()
+		}
+
+		ruleSet, err = r.listRules(ctx, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for namespace, groups := range ruleSet {
+		filtered := groups[:0]
+		for _, group := range groups {
+			if opts.matches(namespace, group.Name) {
+				filtered = append(filtered, group)
+			}
+		}
+
+		if len(filtered) == 0 {
+			delete(ruleSet, namespace)
+		} else {
+			ruleSet[namespace] = filtered
+		}
+	}
+
+	return ruleSet, nil
+}
+
+func (r *MimirClient) listRules(ctx context.Context, path string) (map[string][]rwrulefmt.RuleGroup, error) {
+	res, err := r.doRequest(ctx, path, "GET", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -113,3 +708,228 @@ func (r *MimirClient) ListRules(ctx context.Context, namespace string) (map[stri
 
 	return ruleSet, nil
 }
+
+// listRulesFromStatuses lists the rule groups of the namespace, or of all the namespaces
+// if empty, from the Prometheus-compatible rules API. Like the config API, it returns
+// ErrResourceNotFound if the namespace has no rule groups.
+func (r *MimirClient) listRulesFromStatuses(ctx context.Context, namespace string) (map[string][]rwrulefmt.RuleGroup, error) {
+	statuses, err := r.ListRuleStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ruleSet := map[string][]rwrulefmt.RuleGroup{}
+	for _, status := range statuses {
+		if namespace != "" && status.File != namespace {
+			continue
+		}
+
+		group, err := ruleGroupFromStatus(status)
+		if err != nil {
+			return nil, err
+		}
+		ruleSet[status.File] = append(ruleSet[status.File], group)
+	}
+
+	if namespace != "" && len(ruleSet) == 0 {
+		return nil, ErrResourceNotFound
+	}
+	return ruleSet, nil
+}
+
+// NamespaceRuleGroups is a namespace with its rule groups.
+type NamespaceRuleGroups struct {
+	Namespace string
+	Groups    []rwrulefmt.RuleGroup
+}
+
+// ListRulesOrdered retrieves the rule groups of all the namespaces like ListRules, but
+// keeps both the namespaces and their rule groups in the order the server returned
+// them, for example to export them faithfully.
+func (r *MimirClient) ListRulesOrdered(ctx context.Context) ([]NamespaceRuleGroups, error) {
+	if r.rulesReadAPI == RulesReadAPIPrometheus {
+		statuses, err := r.ListRuleStatuses(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var namespaces []NamespaceRuleGroups
+		indexes := map[string]int{}
+		for _, status := range statuses {
+			group, err := ruleGroupFromStatus(status)
+			if err != nil {
+				return nil, err
+			}
+
+			i, ok := indexes[status.File]
+			if !ok {
+				i = len(namespaces)
+				indexes[status.File] = i
+				namespaces = append(namespaces, NamespaceRuleGroups{Namespace: status.File})
+			}
+			namespaces[i].Groups = append(namespaces[i].Groups, group)
+		}
+		return namespaces, nil
+	}
+
+	res, err := r.doRequest(ctx, r.rulesAPIPath(ctx), "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeOrderedRuleSet(body)
+}
+
+// decodeOrderedRuleSet decodes the rule groups keyed by namespace returned by the config
+// API, keeping the order of the namespaces.
+func decodeOrderedRuleSet(body []byte) ([]NamespaceRuleGroups, error) {
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	ruleSet := doc.Content[0]
+	if ruleSet.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("unexpected rule set, expected a mapping of namespaces, got YAML node kind %d", ruleSet.Kind)
+	}
+
+	namespaces := make([]NamespaceRuleGroups, 0, len(ruleSet.Content)/2)
+	for i := 0; i+1 < len(ruleSet.Content); i += 2 {
+		ns := NamespaceRuleGroups{Namespace: ruleSet.Content[i].Value}
+		if err := ruleSet.Content[i+1].Decode(&ns.Groups); err != nil {
+			return nil, errors.Wrapf(err, "unable to decode the rule groups of namespace %s", ns.Namespace)
+		}
+		namespaces = append(namespaces, ns)
+	}
+	return namespaces, nil
+}
+
+// ListRulesModifiedSince retrieves the rule groups of the tenant modified after the given
+// time, according to the Last-Modified header returned when getting each rule group.
+// The rule groups for which the server doesn't return the header are always included,
+// as their modification time is unknown.
+func (r *MimirClient) ListRulesModifiedSince(ctx context.Context, since time.Time) (map[string][]rwrulefmt.RuleGroup, error) {
+	ruleSet, err := r.ListRules(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for namespace, groups := range ruleSet {
+		modified := groups[:0]
+		for _, group := range groups {
+			lastModified, ok, err := r.ruleGroupLastModified(ctx, namespace, group.Name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to get the modification time of rule group %s/%s", namespace, group.Name)
+			}
+			if !ok || lastModified.After(since) {
+				modified = append(modified, group)
+			}
+		}
+
+		if len(modified) == 0 {
+			delete(ruleSet, namespace)
+		} else {
+			ruleSet[namespace] = modified
+		}
+	}
+
+	return ruleSet, nil
+}
+
+// ruleGroupLastModified returns the modification time of a rule group, as reported by
+// the Last-Modified header, and whether the server returned it.
+func (r *MimirClient) ruleGroupLastModified(ctx context.Context, namespace, groupName string) (time.Time, bool, error) {
+	path := r.rulesAPIPath(ctx) + "/" + url.PathEscape(namespace) + "/" + url.PathEscape(groupName)
+
+	res, err := r.doRequest(ctx, path, "GET", nil)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	res.Body.Close()
+
+	header := res.Header.Get("Last-Modified")
+	if header == "" {
+		return time.Time{}, false, nil
+	}
+
+	lastModified, err := http.ParseTime(header)
+	if err != nil {
+		return time.Time{}, false, errors.Wrapf(err, "invalid Last-Modified header %q", header)
+	}
+	return lastModified, true, nil
+}
+
+// allRuleGroupsPath is the path of the ruler endpoint listing the rule groups of all
+// the tenants.
+const allRuleGroupsPath = "/ruler/rule_groups"
+
+// ListTenants returns the sorted IDs of the tenants having rule groups configured. The
+// tenants are listed from the rule groups of all the tenants returned by the ruler
+// /ruler/rule_groups endpoint, which is not tenant-scoped: it returns the rule groups
+// of every tenant to any caller reaching it, so it's meant for administrators and
+// usually not routed by the gateways exposed to the tenants. The client must target
+// the ruler, or an admin route to it, with its tenant ID and credentials, if any,
+// sent as for the other requests.
+func (r *MimirClient) ListTenants(ctx context.Context) ([]string, error) {
+	res, err := r.doRequest(ctx, allRuleGroupsPath, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// The rule groups of each tenant are not decoded, only the tenant IDs are needed.
+	ruleSets := map[string]yaml.Node{}
+	if err := yaml.Unmarshal(body, &ruleSets); err != nil {
+		r.logEntry(ctx).WithFields(log.Fields{
+			"body": string(body),
+		}).Debugln("failed to unmarshal tenants rule groups from response")
+
+		return nil, errors.Wrap(err, "unable to unmarshal response")
+	}
+
+	tenantIDs := make([]string, 0, len(ruleSets))
+	for tenantID := range ruleSets {
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+	sort.Strings(tenantIDs)
+	return tenantIDs, nil
+}
+
+// ListRulesForTenants retrieves the rule groups of each of the given tenants. The
+// result is keyed by tenant ID and then by namespace. Tenants whose rules can't be
+// retrieved are omitted from the result and reported in a MultiError, by tenant ID.
+// If set, onProgress is called after each tenant.
+func (r *MimirClient) ListRulesForTenants(ctx context.Context, tenantIDs []string, onProgress ProgressFunc) (map[string]map[string][]rwrulefmt.RuleGroup, error) {
+	result := make(map[string]map[string][]rwrulefmt.RuleGroup, len(tenantIDs))
+	errs := &MultiError{}
+
+	for i, tenantID := range tenantIDs {
+		ruleSet, err := r.ListRules(withTenantID(ctx, tenantID), "")
+		onProgress.report(i+1, len(tenantIDs))
+		if err != nil {
+			errs.Add(tenantID, errors.Wrap(err, "unable to list rules"))
+			if r.stopOnFirstError {
+				break
+			}
+			continue
+		}
+
+		result[tenantID] = ruleSet
+	}
+
+	return result, errs.Err()
+}
@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+// MarshalRulesJSON returns the JSON encoding of a rule set, as returned by ListRules, for
+// example to process it with scripts. The output is stable: the namespaces and the keys
+// of the objects are sorted, as are the rule groups of each namespace by name. The rule
+// groups have the same fields as in the YAML format.
+func MarshalRulesJSON(ruleSet map[string][]rwrulefmt.RuleGroup) ([]byte, error) {
+	sorted := make(map[string][]rwrulefmt.RuleGroup, len(ruleSet))
+	for ns, groups := range ruleSet {
+		groups = append([]rwrulefmt.RuleGroup(nil), groups...)
+		sortRuleGroups(groups)
+		sorted[ns] = groups
+	}
+
+	// The rules hold their fields as YAML nodes, so the rule set is converted through its
+	// YAML encoding. The maps are then sorted by key when encoded to JSON.
+	content, err := yaml.Marshal(sorted)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal rules")
+	}
+
+	var decoded map[string]interface{}
+	if err := yaml.Unmarshal(content, &decoded); err != nil {
+		return nil, errors.Wrap(err, "unable to marshal rules")
+	}
+	if decoded == nil {
+		decoded = map[string]interface{}{}
+	}
+
+	// The expressions are not HTML escaped, so that comparison operators are kept as is.
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(decoded); err != nil {
+		return nil, errors.Wrap(err, "unable to marshal rules")
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+func TestMarshalRulesJSON(t *testing.T) {
+	alertingGroup := rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{
+		Name:     "alerts",
+		Interval: model.Duration(2 * 60 * 1e9),
+		Rules: []rulefmt.RuleNode{{
+			Alert:       yaml.Node{Kind: yaml.ScalarNode, Value: "HighErrorRate"},
+			Expr:        yaml.Node{Kind: yaml.ScalarNode, Value: "errors > 10"},
+			For:         model.Duration(5 * 60 * 1e9),
+			Labels:      map[string]string{"team": "a", "severity": "critical"},
+			Annotations: map[string]string{"summary": "High error rate"},
+		}},
+	}}
+
+	ruleSet := map[string][]rwrulefmt.RuleGroup{
+		"namespace-2": {alertingGroup},
+		"namespace-1": {newTestRuleGroup("group-2", "metric:sum"), newTestRuleGroup("group-1", "metric:sum", "metric:count")},
+	}
+
+	expected := `{` +
+		`"namespace-1":[` +
+		`{"name":"group-1","rules":[{"expr":"sum(metric)","record":"metric:sum"},{"expr":"sum(metric)","record":"metric:count"}]},` +
+		`{"name":"group-2","rules":[{"expr":"sum(metric)","record":"metric:sum"}]}` +
+		`],` +
+		`"namespace-2":[` +
+		`{"interval":"2m","name":"alerts","rules":[{"alert":"HighErrorRate","annotations":{"summary":"High error rate"},"expr":"errors > 10","for":"5m","labels":{"severity":"critical","team":"a"}}]}` +
+		`]` +
+		`}`
+
+	// The output doesn't depend on the iteration order of the maps.
+	for i := 0; i < 10; i++ {
+		output, err := MarshalRulesJSON(ruleSet)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(output))
+	}
+
+	// The rule groups aren't reordered in place.
+	assert.Equal(t, "group-2", ruleSet["namespace-1"][0].Name)
+
+	output, err := MarshalRulesJSON(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(output))
+}
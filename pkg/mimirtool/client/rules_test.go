@@ -8,11 +8,25 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
 )
 
 func TestMimirClient_X(t *testing.T) {
@@ -79,3 +93,1433 @@ func TestMimirClient_X(t *testing.T) {
 	}
 
 }
+
+func TestMimirClient_ListRulesForTenants(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Scope-OrgID") {
+		case "tenant-1":
+			fmt.Fprint(w, "ns-1:\n  - name: group-1\n    rules:\n      - record: metric:sum\n        expr: sum(metric)\n")
+		case "tenant-2":
+			fmt.Fprint(w, "ns-2:\n  - name: group-2\n    rules:\n      - alert: Down\n        expr: up == 0\n")
+		default:
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{
+		Address: ts.URL,
+		ID:      "my-id",
+	})
+	require.NoError(t, err)
+
+	t.Run("rules are keyed by tenant", func(t *testing.T) {
+		result, err := client.ListRulesForTenants(context.Background(), []string{"tenant-1", "tenant-2"}, nil)
+		require.NoError(t, err)
+		require.Len(t, result, 2)
+
+		require.Len(t, result["tenant-1"]["ns-1"], 1)
+		assert.Equal(t, "group-1", result["tenant-1"]["ns-1"][0].Name)
+		assert.NotContains(t, result["tenant-1"], "ns-2")
+
+		require.Len(t, result["tenant-2"]["ns-2"], 1)
+		assert.Equal(t, "group-2", result["tenant-2"]["ns-2"][0].Name)
+		assert.NotContains(t, result["tenant-2"], "ns-1")
+	})
+
+	t.Run("per-tenant errors are aggregated", func(t *testing.T) {
+		result, err := client.ListRulesForTenants(context.Background(), []string{"tenant-1", "tenant-3", "tenant-4"}, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "tenant-3")
+		assert.Contains(t, err.Error(), "tenant-4")
+
+		require.Len(t, result, 1)
+		assert.Contains(t, result, "tenant-1")
+	})
+}
+
+func TestMimirClient_ListRulesFiltered(t *testing.T) {
+	queryCh := make(chan url.Values, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queryCh <- r.URL.Query()
+
+		// The server ignores the filters, which are applied by the client.
+		fmt.Fprint(w, `
+ns-1:
+  - name: group-1
+    rules:
+      - record: metric:sum
+        expr: sum(metric)
+  - name: group-2
+    rules:
+      - record: metric:count
+        expr: count(metric)
+ns-2:
+  - name: group-1
+    rules:
+      - record: metric:max
+        expr: max(metric)
+`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		opts           ListOptions
+		expectedQuery  url.Values
+		expectedGroups map[string][]string
+	}{
+		"no filters": {
+			opts:           ListOptions{},
+			expectedQuery:  url.Values{},
+			expectedGroups: map[string][]string{"ns-1": {"group-1", "group-2"}, "ns-2": {"group-1"}},
+		},
+		"file": {
+			opts:           ListOptions{File: "ns-1"},
+			expectedQuery:  url.Values{"file": {"ns-1"}},
+			expectedGroups: map[string][]string{"ns-1": {"group-1", "group-2"}},
+		},
+		"rule group": {
+			opts:           ListOptions{RuleGroup: "group-1"},
+			expectedQuery:  url.Values{"rule_group": {"group-1"}},
+			expectedGroups: map[string][]string{"ns-1": {"group-1"}, "ns-2": {"group-1"}},
+		},
+		"file and rule group": {
+			opts:           ListOptions{File: "ns-1", RuleGroup: "group-2"},
+			expectedQuery:  url.Values{"file": {"ns-1"}, "rule_group": {"group-2"}},
+			expectedGroups: map[string][]string{"ns-1": {"group-2"}},
+		},
+		"no match": {
+			opts:           ListOptions{File: "ns-2", RuleGroup: "group-2"},
+			expectedQuery:  url.Values{"file": {"ns-2"}, "rule_group": {"group-2"}},
+			expectedGroups: map[string][]string{},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ruleSet, err := client.ListRulesFiltered(context.Background(), testData.opts)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedQuery, <-queryCh)
+
+			actualGroups := map[string][]string{}
+			for namespace, groups := range ruleSet {
+				for _, group := range groups {
+					actualGroups[namespace] = append(actualGroups[namespace], group.Name)
+				}
+			}
+			assert.Equal(t, testData.expectedGroups, actualGroups)
+		})
+	}
+}
+
+func TestMimirClient_ListRulesFilteredSharded(t *testing.T) {
+	const numNamespaces, numGroups, shardCount = 5, 10, 2
+
+	queryCh := make(chan url.Values, shardCount)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queryCh <- r.URL.Query()
+
+		// The shard is applied by the client.
+		for ns := 0; ns < numNamespaces; ns++ {
+			fmt.Fprintf(w, "ns-%d:\n", ns)
+			for g := 0; g < numGroups; g++ {
+				fmt.Fprintf(w, "  - name: group-%d\n    rules: [{record: \"metric:sum\", expr: \"sum(metric)\"}]\n", g)
+			}
+		}
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	seen := map[string]int{}
+	for shardIndex := 0; shardIndex < shardCount; shardIndex++ {
+		ruleSet, err := client.ListRulesFiltered(context.Background(), ListOptions{ShardIndex: shardIndex, ShardCount: shardCount})
+		require.NoError(t, err)
+		assert.Empty(t, <-queryCh, "the shard is not sent to the server")
+
+		shardGroups := 0
+		for namespace, groups := range ruleSet {
+			for _, group := range groups {
+				seen[namespace+"/"+group.Name]++
+				shardGroups++
+			}
+		}
+		assert.NotZero(t, shardGroups, "shard %d", shardIndex)
+	}
+
+	// The shards are disjoint and cover all the rule groups.
+	require.Len(t, seen, numNamespaces*numGroups)
+	for group, count := range seen {
+		assert.Equal(t, 1, count, group)
+	}
+
+	_, err = client.ListRulesFiltered(context.Background(), ListOptions{ShardIndex: 2, ShardCount: 2})
+	require.EqualError(t, err, "invalid shard 2 of 2")
+}
+
+func TestMimirClient_ListRulesModifiedSince(t *testing.T) {
+	since := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	lastModified := map[string]time.Time{
+		"/api/v1/rules/ns-1/old":    since.Add(-time.Hour),
+		"/api/v1/rules/ns-1/new":    since.Add(time.Hour),
+		"/api/v1/rules/ns-2/old":    since.Add(-time.Minute),
+		"/api/v1/rules/ns-3/same":   since,
+		"/api/v1/rules/ns-3/recent": since.Add(time.Second),
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/rules" {
+			fmt.Fprint(w, `
+ns-1:
+  - name: old
+    rules: [{record: "metric:sum", expr: "sum(metric)"}]
+  - name: new
+    rules: [{record: "metric:sum", expr: "sum(metric)"}]
+ns-2:
+  - name: old
+    rules: [{record: "metric:sum", expr: "sum(metric)"}]
+ns-3:
+  - name: same
+    rules: [{record: "metric:sum", expr: "sum(metric)"}]
+  - name: recent
+    rules: [{record: "metric:sum", expr: "sum(metric)"}]
+  - name: unknown
+    rules: [{record: "metric:sum", expr: "sum(metric)"}]
+`)
+			return
+		}
+
+		if modTime, ok := lastModified[r.URL.Path]; ok {
+			w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+		}
+		fmt.Fprint(w, "name: group\nrules: []\n")
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	ruleSet, err := client.ListRulesModifiedSince(context.Background(), since)
+	require.NoError(t, err)
+
+	names := map[string][]string{}
+	for namespace, groups := range ruleSet {
+		for _, group := range groups {
+			names[namespace] = append(names[namespace], group.Name)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"ns-1": {"new"},
+		// Rule groups without a modification time are always included.
+		"ns-3": {"recent", "unknown"},
+	}, names)
+}
+
+func TestMimirClient_ListRulesOrdered(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/rules", r.URL.Path)
+		fmt.Fprint(w, `
+zeta:
+  - name: second
+    rules: [{record: "metric:sum", expr: "sum(metric)"}]
+  - name: first
+    rules: [{record: "metric:sum", expr: "sum(metric)"}]
+alpha:
+  - name: only
+    rules: [{record: "metric:sum", expr: "sum(metric)"}]
+middle:
+  - name: c
+    rules: [{record: "metric:sum", expr: "sum(metric)"}]
+  - name: a
+    rules: [{record: "metric:sum", expr: "sum(metric)"}]
+  - name: b
+    rules: [{record: "metric:sum", expr: "sum(metric)"}]
+`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	namespaces, err := client.ListRulesOrdered(context.Background())
+	require.NoError(t, err)
+
+	var names []string
+	for _, ns := range namespaces {
+		for _, group := range ns.Groups {
+			names = append(names, ns.Namespace+"/"+group.Name)
+		}
+	}
+	assert.Equal(t, []string{
+		"zeta/second",
+		"zeta/first",
+		"alpha/only",
+		"middle/c",
+		"middle/a",
+		"middle/b",
+	}, names)
+}
+
+func TestMimirClient_CreateRuleGroupWithEmptyName(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{
+		Address: ts.URL,
+		ID:      "my-id",
+	})
+	require.NoError(t, err)
+
+	err = client.CreateRuleGroup(context.Background(), "my-namespace", rwrulefmt.RuleGroup{
+		RuleGroup: rulefmt.RuleGroup{Name: ""},
+	})
+	require.ErrorIs(t, err, ErrEmptyRuleGroupName)
+	require.Equal(t, 0, requests)
+}
+
+func TestMimirClient_AutoDetectAPIVersion(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		buildInfo       string
+		useLegacyRoutes bool
+		expPath         string
+	}{
+		{
+			name:      "server reporting the ruler config API feature",
+			buildInfo: `{"status":"success","data":{"version":"2.0.0","features":{"ruler_config_api":"true"}}}`,
+			expPath:   "/api/v1/rules/my-namespace/my-group",
+		},
+		{
+			name:      "server not reporting the ruler config API feature",
+			buildInfo: `{"status":"success","data":{"version":"1.10.0"}}`,
+			expPath:   "/api/prom/rules/my-namespace/my-group",
+		},
+		{
+			name:            "detection failure falls back to the configured routes",
+			useLegacyRoutes: true,
+			expPath:         "/api/prom/rules/my-namespace/my-group",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buildInfoRequests int
+			requestCh := make(chan *http.Request, 10)
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/api/v1/status/buildinfo" {
+					buildInfoRequests++
+					if tc.buildInfo == "" {
+						http.NotFound(w, r)
+						return
+					}
+					fmt.Fprint(w, tc.buildInfo)
+					return
+				}
+				requestCh <- r
+			}))
+			defer ts.Close()
+
+			client, err := New(Config{
+				Address:              ts.URL,
+				ID:                   "my-id",
+				UseLegacyRoutes:      tc.useLegacyRoutes,
+				AutoDetectAPIVersion: true,
+			})
+			require.NoError(t, err)
+
+			for i := 0; i < 2; i++ {
+				require.NoError(t, client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group"))
+
+				req := <-requestCh
+				assert.Equal(t, tc.expPath, req.URL.Path)
+			}
+
+			// The detection is done once for the lifetime of the client.
+			assert.Equal(t, 1, buildInfoRequests)
+		})
+	}
+}
+
+func TestMimirClient_CreateRuleGroupExceedingMaxRulesPerGroup(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer ts.Close()
+
+	rg := rwrulefmt.RuleGroup{
+		RuleGroup: rulefmt.RuleGroup{
+			Name: "my-group",
+			Rules: []rulefmt.RuleNode{
+				{Record: yaml.Node{Kind: yaml.ScalarNode, Value: "metric:sum"}, Expr: yaml.Node{Kind: yaml.ScalarNode, Value: "sum(metric)"}},
+				{Record: yaml.Node{Kind: yaml.ScalarNode, Value: "metric:max"}, Expr: yaml.Node{Kind: yaml.ScalarNode, Value: "max(metric)"}},
+				{Record: yaml.Node{Kind: yaml.ScalarNode, Value: "metric:min"}, Expr: yaml.Node{Kind: yaml.ScalarNode, Value: "min(metric)"}},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name        string
+		limit       int
+		expectedErr string
+	}{
+		{name: "unlimited", limit: 0},
+		{name: "within the limit", limit: 3},
+		{name: "exceeding the limit", limit: 2, expectedErr: `rule group "my-group" has 3 rules, exceeding the limit of 2 rules per group`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			requests = 0
+
+			client, err := New(Config{
+				Address:          ts.URL,
+				ID:               "my-id",
+				MaxRulesPerGroup: tc.limit,
+			})
+			require.NoError(t, err)
+
+			err = client.CreateRuleGroup(context.Background(), "my-namespace", rg)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				require.Equal(t, 0, requests)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, 1, requests)
+		})
+	}
+}
+
+func TestMimirClient_CreateRuleGroupWithInvalidRecordName(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	rg := newTestRuleGroup("my-group", "metric:sum", "metric-sum", "1metric")
+	rg.Rules = append(rg.Rules, rulefmt.RuleNode{
+		// Alerting rules are not checked.
+		Alert: yaml.Node{Kind: yaml.ScalarNode, Value: "High-Error-Rate"},
+		Expr:  yaml.Node{Kind: yaml.ScalarNode, Value: "errors > 10"},
+	})
+
+	err = client.CreateRuleGroup(context.Background(), "my-namespace", rg)
+	require.EqualError(t, err, `rule group "my-group" has recording rules whose record field is not a valid metric name: "metric-sum" (rule 1), "1metric" (rule 2)`)
+	assert.Equal(t, 0, requests)
+
+	require.NoError(t, client.CreateRuleGroup(context.Background(), "my-namespace", newTestRuleGroup("my-group", "metric:sum", "job:metric:rate5m")))
+	assert.Equal(t, 1, requests)
+}
+
+func TestMimirClient_CreateRuleGroupWithRequiredFields(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer ts.Close()
+
+	rg := rwrulefmt.RuleGroup{
+		RuleGroup: rulefmt.RuleGroup{
+			Name: "my-group",
+			Rules: []rulefmt.RuleNode{
+				{
+					Alert:       yaml.Node{Kind: yaml.ScalarNode, Value: "HighErrorRate"},
+					Expr:        yaml.Node{Kind: yaml.ScalarNode, Value: "errors > 10"},
+					Labels:      map[string]string{"severity": "critical"},
+					Annotations: map[string]string{"summary": "High error rate"},
+				},
+				{
+					Alert:       yaml.Node{Kind: yaml.ScalarNode, Value: "HighLatency"},
+					Expr:        yaml.Node{Kind: yaml.ScalarNode, Value: "latency > 1"},
+					Annotations: map[string]string{"summary": "High latency"},
+				},
+				// Recording rules are not checked.
+				{Record: yaml.Node{Kind: yaml.ScalarNode, Value: "metric:sum"}, Expr: yaml.Node{Kind: yaml.ScalarNode, Value: "sum(metric)"}},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name               string
+		requireLabels      []string
+		requireAnnotations []string
+		expectedErr        string
+	}{
+		{name: "no required fields"},
+		{name: "required fields set", requireAnnotations: []string{"summary"}},
+		{
+			name:               "required label missing",
+			requireLabels:      []string{"severity"},
+			requireAnnotations: []string{"summary"},
+			expectedErr:        `rule group "my-group" has alerting rules missing required fields: HighLatency (missing label severity)`,
+		},
+		{
+			name:               "required label and annotations missing",
+			requireLabels:      []string{"severity"},
+			requireAnnotations: []string{"summary", "runbook_url"},
+			expectedErr:        `rule group "my-group" has alerting rules missing required fields: HighErrorRate (missing annotation runbook_url); HighLatency (missing label severity, annotation runbook_url)`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			requests = 0
+
+			client, err := New(Config{
+				Address:            ts.URL,
+				ID:                 "my-id",
+				RequireLabels:      tc.requireLabels,
+				RequireAnnotations: tc.requireAnnotations,
+			})
+			require.NoError(t, err)
+
+			err = client.CreateRuleGroup(context.Background(), "my-namespace", rg)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				require.Equal(t, 0, requests)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, 1, requests)
+		})
+	}
+}
+
+func TestMimirClient_CreateRuleGroupEvaluationInterval(t *testing.T) {
+	var (
+		requests int
+		posted   rwrulefmt.RuleGroup
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		posted = rwrulefmt.RuleGroup{}
+		require.NoError(t, yaml.Unmarshal(body, &posted))
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		name             string
+		interval         time.Duration
+		defaultInterval  time.Duration
+		expectedInterval time.Duration
+		expectedErr      string
+	}{
+		{name: "above the minimum", interval: 2 * time.Minute, expectedInterval: 2 * time.Minute},
+		{name: "equal to the minimum", interval: time.Minute, expectedInterval: time.Minute},
+		{name: "below the minimum", interval: 30 * time.Second, expectedErr: `rule group "my-group" has an evaluation interval of 30s, lower than the minimum of 1m0s`},
+		{name: "zero interval", interval: 0, expectedInterval: 0},
+		{name: "zero interval, default configured", interval: 0, defaultInterval: 5 * time.Minute, expectedInterval: 5 * time.Minute},
+		{name: "interval set, default configured", interval: 2 * time.Minute, defaultInterval: 5 * time.Minute, expectedInterval: 2 * time.Minute},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			requests = 0
+
+			client, err := New(Config{
+				Address:                   ts.URL,
+				ID:                        "my-id",
+				MinEvaluationInterval:     time.Minute,
+				DefaultEvaluationInterval: tc.defaultInterval,
+			})
+			require.NoError(t, err)
+
+			rg := newTestRuleGroup("my-group", "metric:sum")
+			rg.Interval = model.Duration(tc.interval)
+
+			err = client.CreateRuleGroup(context.Background(), "my-namespace", rg)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				require.Equal(t, 0, requests)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, 1, requests)
+			assert.Equal(t, model.Duration(tc.expectedInterval), posted.Interval)
+		})
+	}
+
+	t.Run("default below the minimum", func(t *testing.T) {
+		_, err := New(Config{
+			Address:                   ts.URL,
+			ID:                        "my-id",
+			MinEvaluationInterval:     time.Minute,
+			DefaultEvaluationInterval: 30 * time.Second,
+		})
+		require.EqualError(t, err, "the default evaluation interval 30s is lower than the min evaluation interval 1m0s")
+	})
+}
+
+func TestMimirClient_GetRuleGroupWithMeta(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-By", "ruler-2")
+		fmt.Fprint(w, "name: my-group\nrules:\n  - record: metric:sum\n    expr: sum(metric)\n")
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{
+		Address: ts.URL,
+		ID:      "my-id",
+	})
+	require.NoError(t, err)
+
+	rg, header, err := client.GetRuleGroupWithMeta(context.Background(), "my-namespace", "my-group")
+	require.NoError(t, err)
+	assert.Equal(t, "my-group", rg.Name)
+	require.Len(t, rg.Rules, 1)
+	assert.Equal(t, "metric:sum", rg.Rules[0].Record.Value)
+	assert.Equal(t, "ruler-2", header.Get("X-Served-By"))
+}
+
+func TestMimirClient_DeleteRuleGroupIfMatch(t *testing.T) {
+	const currentETag = `"v2"`
+
+	var deleted []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodDelete, r.Method)
+
+		if r.Header.Get("If-Match") != currentETag {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		deleted = append(deleted, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{
+		Address: ts.URL,
+		ID:      "my-id",
+	})
+	require.NoError(t, err)
+
+	t.Run("matching ETag", func(t *testing.T) {
+		deleted = nil
+
+		require.NoError(t, client.DeleteRuleGroupIfMatch(context.Background(), "my-namespace", "my-group", currentETag))
+		assert.Equal(t, []string{"/api/v1/rules/my-namespace/my-group"}, deleted)
+	})
+
+	t.Run("mismatching ETag", func(t *testing.T) {
+		deleted = nil
+
+		err := client.DeleteRuleGroupIfMatch(context.Background(), "my-namespace", "my-group", `"v1"`)
+		require.ErrorIs(t, err, ErrConflict)
+		assert.Empty(t, deleted)
+	})
+}
+
+func TestMimirClient_IdempotencyKeys(t *testing.T) {
+	requestCh := make(chan *http.Request, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCh <- r
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{
+		Address:            ts.URL,
+		ID:                 "my-id",
+		UseIdempotencyKeys: true,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	rg := newTestRuleGroup("my-group", "metric:sum")
+	payload, err := yaml.Marshal(&rg)
+	require.NoError(t, err)
+
+	// Identical payloads get the same key.
+	require.NoError(t, client.CreateRuleGroup(ctx, "my-namespace", rg))
+	require.NoError(t, client.CreateRuleGroup(ctx, "my-namespace", rg))
+	first, second := <-requestCh, <-requestCh
+	expectedKey := idempotencyKey("my-id", http.MethodPost, "/api/v1/rules/my-namespace", payload)
+	assert.Equal(t, expectedKey, first.Header.Get("Idempotency-Key"))
+	assert.Equal(t, expectedKey, second.Header.Get("Idempotency-Key"))
+
+	// A different payload gets a different key.
+	require.NoError(t, client.CreateRuleGroup(ctx, "my-namespace", newTestRuleGroup("my-group", "metric:max")))
+	assert.NotEqual(t, expectedKey, (<-requestCh).Header.Get("Idempotency-Key"))
+
+	// Deletes get a key too, while reads don't.
+	require.NoError(t, client.DeleteRuleGroup(ctx, "my-namespace", "my-group"))
+	assert.Equal(t, idempotencyKey("my-id", http.MethodDelete, "/api/v1/rules/my-namespace/my-group", nil), (<-requestCh).Header.Get("Idempotency-Key"))
+
+	_, _ = client.GetRuleGroup(ctx, "my-namespace", "my-group")
+	assert.Empty(t, (<-requestCh).Header.Get("Idempotency-Key"))
+
+	// The same payload sent for another tenant gets a different key.
+	otherClient, err := New(Config{
+		Address:            ts.URL,
+		ID:                 "other-id",
+		UseIdempotencyKeys: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, otherClient.CreateRuleGroup(ctx, "my-namespace", rg))
+	other := <-requestCh
+	assert.Equal(t, "other-id", other.Header.Get("X-Scope-OrgID"))
+	assert.NotEqual(t, expectedKey, other.Header.Get("Idempotency-Key"))
+}
+
+func TestMimirClient_CreateRuleGroupAndWait(t *testing.T) {
+	// Make the test faster.
+	prevInterval := ruleGroupPollInterval
+	ruleGroupPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { ruleGroupPollInterval = prevInterval })
+
+	const visibleAfterGets = 3
+
+	var (
+		mtx     sync.Mutex
+		pending []byte
+		stored  []byte
+		gets    int
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		switch r.Method {
+		case http.MethodPost:
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			pending, gets = body, 0
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodGet:
+			// Simulate the rule group being eventually stored.
+			if gets++; gets >= visibleAfterGets {
+				stored = pending
+			}
+			if stored == nil {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(stored)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	t.Run("waits until the rule group is visible", func(t *testing.T) {
+		rg := newTestRuleGroup("group", "metric:sum")
+		require.NoError(t, client.CreateRuleGroupAndWait(context.Background(), "namespace", rg))
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		assert.Equal(t, visibleAfterGets, gets)
+	})
+
+	t.Run("waits until the rule group has the new content", func(t *testing.T) {
+		rg := newTestRuleGroup("group", "metric:sum", "metric:count")
+		require.NoError(t, client.CreateRuleGroupAndWait(context.Background(), "namespace", rg))
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		assert.Equal(t, visibleAfterGets, gets)
+	})
+
+	t.Run("gives up when the context expires", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+
+		mtx.Lock()
+		stored = nil
+		mtx.Unlock()
+
+		err := client.CreateRuleGroupAndWait(ctx, "namespace", newTestRuleGroup("group", "metric:max"))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestMimirClient_DeleteNamespace(t *testing.T) {
+	requestCh := make(chan *http.Request, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCh <- r
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	tests := map[string]struct {
+		requireConfirmation bool
+		confirm             string
+		expectedErr         error
+	}{
+		"confirmation not required": {
+			requireConfirmation: false,
+		},
+		"confirmed": {
+			requireConfirmation: true,
+			confirm:             "my-namespace",
+		},
+		"unconfirmed": {
+			requireConfirmation: true,
+			expectedErr:         ErrConfirmationRequired,
+		},
+		"confirmed with another namespace": {
+			requireConfirmation: true,
+			confirm:             "another-namespace",
+			expectedErr:         ErrConfirmationRequired,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			client, err := New(Config{Address: ts.URL, ID: "my-id", RequireDeleteConfirmation: testData.requireConfirmation})
+			require.NoError(t, err)
+
+			err = client.DeleteNamespace(context.Background(), "my-namespace", testData.confirm)
+			if testData.expectedErr != nil {
+				require.ErrorIs(t, err, testData.expectedErr)
+				assert.Len(t, requestCh, 0)
+				return
+			}
+
+			require.NoError(t, err)
+			req := <-requestCh
+			assert.Equal(t, http.MethodDelete, req.Method)
+			assert.Equal(t, "/api/v1/rules/my-namespace", req.URL.Path)
+		})
+	}
+}
+
+func TestMimirClient_RulesReadAPIPrometheus(t *testing.T) {
+	var (
+		requestsMtx sync.Mutex
+		requests    []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsMtx.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		requestsMtx.Unlock()
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if r.URL.Path != "/prometheus/api/v1/rules" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"status": "success",
+			"data": {
+				"groups": [
+					{
+						"name": "group-1",
+						"file": "namespace-1",
+						"interval": 60,
+						"rules": [
+							{"name": "metric:sum", "query": "sum(metric)", "type": "recording", "health": "ok", "labels": {"team": "a"}}
+						]
+					},
+					{
+						"name": "group-2",
+						"file": "namespace-1",
+						"interval": 30,
+						"rules": [
+							{"name": "HighErrorRate", "query": "errors > 10", "type": "alerting", "health": "ok", "duration": 300, "labels": {"severity": "critical"}, "annotations": {"summary": "High error rate"}}
+						]
+					},
+					{
+						"name": "group-3",
+						"file": "namespace-2",
+						"interval": 60,
+						"rules": [
+							{"name": "metric:count", "query": "count(metric)", "type": "recording", "health": "unknown"}
+						]
+					}
+				]
+			}
+		}`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", RulesReadAPI: RulesReadAPIPrometheus})
+	require.NoError(t, err)
+
+	ruleSet, err := client.ListRules(context.Background(), "")
+	require.NoError(t, err)
+
+	alerting := rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{
+		Name:     "group-2",
+		Interval: model.Duration(30 * time.Second),
+		Rules: []rulefmt.RuleNode{{
+			Alert:       yaml.Node{Kind: yaml.ScalarNode, Value: "HighErrorRate"},
+			Expr:        yaml.Node{Kind: yaml.ScalarNode, Value: "errors > 10"},
+			For:         model.Duration(5 * time.Minute),
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "High error rate"},
+		}},
+	}}
+	recording := newTestRuleGroup("group-1", "metric:sum")
+	recording.Interval = model.Duration(time.Minute)
+	recording.Rules[0].Labels = map[string]string{"team": "a"}
+
+	require.Len(t, ruleSet, 2)
+	assert.Equal(t, []rwrulefmt.RuleGroup{recording, alerting}, ruleSet["namespace-1"])
+	require.Len(t, ruleSet["namespace-2"], 1)
+	assert.Equal(t, "count(metric)", ruleSet["namespace-2"][0].Rules[0].Expr.Value)
+
+	ruleSet, err = client.ListRules(context.Background(), "namespace-2")
+	require.NoError(t, err)
+	assert.Len(t, ruleSet, 1)
+	assert.Len(t, ruleSet["namespace-2"], 1)
+
+	_, err = client.ListRules(context.Background(), "missing")
+	require.ErrorIs(t, err, ErrResourceNotFound)
+
+	ruleSet, err = client.ListRulesFiltered(context.Background(), ListOptions{File: "namespace-1", RuleGroup: "group-2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]rwrulefmt.RuleGroup{"namespace-1": {alerting}}, ruleSet)
+
+	// The writes still use the config API.
+	requests = nil
+	require.NoError(t, client.CreateRuleGroup(context.Background(), "namespace-1", recording))
+	assert.Equal(t, []string{"POST /api/v1/rules/namespace-1"}, requests)
+
+	_, err = New(Config{Address: ts.URL, ID: "my-id", RulesReadAPI: "unknown"})
+	require.EqualError(t, err, `unknown rules read API "unknown"`)
+}
+
+func TestMimirClient_CreateRuleGroupValidateOnly(t *testing.T) {
+	var (
+		storedMtx sync.Mutex
+		stored    []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rg := rwrulefmt.RuleGroup{}
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, yaml.Unmarshal(body, &rg))
+
+		if len(rg.Rules) > 1 {
+			http.Error(w, "per-user rules per rule group limit (limit: 1 actual: 2) exceeded", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("validate_only") == "true" {
+			return
+		}
+
+		storedMtx.Lock()
+		defer storedMtx.Unlock()
+		stored = append(stored, rg.Name)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	opts := CreateOptions{ValidateOnly: true}
+	require.NoError(t, client.CreateRuleGroupWithOptions(context.Background(), "my-namespace", newTestRuleGroup("valid", "metric:sum"), opts))
+
+	err = client.CreateRuleGroupWithOptions(context.Background(), "my-namespace", newTestRuleGroup("invalid", "metric:sum", "metric:count"), opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "per-user rules per rule group limit")
+	assert.Empty(t, stored)
+
+	require.NoError(t, client.CreateRuleGroupWithOptions(context.Background(), "my-namespace", newTestRuleGroup("valid", "metric:sum"), CreateOptions{}))
+	assert.Equal(t, []string{"valid"}, stored)
+}
+
+func TestMimirClient_CreateRuleGroupSkipUnchanged(t *testing.T) {
+	var (
+		mtx    sync.Mutex
+		stored = map[string]string{
+			// The stored rule group is formatted differently than the one sent, but has
+			// the same content.
+			"my-group": "name: my-group\nrules:\n  - record: 'metric:sum'\n    expr: \"sum(metric)\"\n",
+		}
+		posts int
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		switch r.Method {
+		case http.MethodGet:
+			group, ok := stored[path.Base(r.URL.Path)]
+			if !ok {
+				http.Error(w, "group does not exist", http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, group)
+		case http.MethodPost:
+			posts++
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			rg := rwrulefmt.RuleGroup{}
+			require.NoError(t, yaml.Unmarshal(body, &rg))
+			stored[rg.Name] = string(body)
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", SkipUnchanged: true})
+	require.NoError(t, err)
+
+	// The pre-check doesn't write anything to stdout, which the callers may use for their
+	// own output.
+	var res CreateResult
+	stdout := captureStdout(t, func() {
+		res, err = client.CreateRuleGroupWithResult(context.Background(), "my-namespace", newTestRuleGroup("my-group", "metric:sum"))
+	})
+	require.NoError(t, err)
+	assert.True(t, res.Unchanged)
+	assert.Empty(t, stdout)
+	assert.Equal(t, 0, posts)
+
+	// The changed and missing rule groups are uploaded.
+	res, err = client.CreateRuleGroupWithResult(context.Background(), "my-namespace", newTestRuleGroup("my-group", "metric:sum", "metric:count"))
+	require.NoError(t, err)
+	assert.False(t, res.Unchanged)
+	assert.Equal(t, 1, posts)
+
+	require.NoError(t, client.CreateRuleGroup(context.Background(), "my-namespace", newTestRuleGroup("other-group", "metric:sum")))
+	assert.Equal(t, 2, posts)
+
+	// Once stored, they're not uploaded again.
+	require.NoError(t, client.CreateRuleGroupAndWait(context.Background(), "my-namespace", newTestRuleGroup("other-group", "metric:sum")))
+	assert.Equal(t, 2, posts)
+
+	// Without SkipUnchanged, the rule groups are always uploaded.
+	client, err = New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+	res, err = client.CreateRuleGroupWithResult(context.Background(), "my-namespace", newTestRuleGroup("other-group", "metric:sum"))
+	require.NoError(t, err)
+	assert.False(t, res.Unchanged)
+	assert.Equal(t, 3, posts)
+}
+
+func TestMimirClient_CreateRuleGroupWarnings(t *testing.T) {
+	posts := atomic.NewInt32(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts.Inc()
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	rg := rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{
+		Name:     "my-group",
+		Interval: model.Duration(5 * time.Minute),
+		Rules: []rulefmt.RuleNode{
+			{
+				Alert: yaml.Node{Kind: yaml.ScalarNode, Value: "TooShortFor"},
+				Expr:  yaml.Node{Kind: yaml.ScalarNode, Value: "up < 1"},
+				For:   model.Duration(time.Minute),
+			},
+			{
+				Alert: yaml.Node{Kind: yaml.ScalarNode, Value: "LongEnoughFor"},
+				Expr:  yaml.Node{Kind: yaml.ScalarNode, Value: "up < 1"},
+				For:   model.Duration(10 * time.Minute),
+			},
+			{
+				Alert: yaml.Node{Kind: yaml.ScalarNode, Value: "NoFor"},
+				Expr:  yaml.Node{Kind: yaml.ScalarNode, Value: "up < 1"},
+			},
+		},
+	}}
+
+	// The rule group is created anyway.
+	res, err := client.CreateRuleGroupWithResult(context.Background(), "my-namespace", rg)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), posts.Load())
+	assert.Equal(t, []ValidationWarning{{
+		Group: "my-group",
+		Rule:  "TooShortFor",
+		Msg:   "the for duration 1m is lower than the evaluation interval 5m of the rule group",
+	}}, res.Warnings)
+
+	// Without an interval, the rule group uses the default of the server, so it's not checked.
+	rg.Interval = 0
+	res, err = client.CreateRuleGroupWithResult(context.Background(), "my-namespace", rg)
+	require.NoError(t, err)
+	assert.Empty(t, res.Warnings)
+
+	// The default evaluation interval of the client is checked against.
+	client, err = New(Config{Address: ts.URL, ID: "my-id", DefaultEvaluationInterval: 2 * time.Minute})
+	require.NoError(t, err)
+	res, err = client.CreateRuleGroupWithResult(context.Background(), "my-namespace", rg)
+	require.NoError(t, err)
+	require.Len(t, res.Warnings, 1)
+	assert.Equal(t, "TooShortFor", res.Warnings[0].Rule)
+}
+
+func TestMimirClient_GetRuleGroupParseError(t *testing.T) {
+	tests := map[string]struct {
+		body          string
+		expectedLine  int
+		expectedCol   int
+		expectedError string
+	}{
+		"syntax error": {
+			body:          "name: my-group\nrules:\n  - record: metric:sum\n    expr: sum(metric)\n    labels: a: b\n",
+			expectedLine:  5,
+			expectedError: "unable to unmarshal response: line 5: mapping values are not allowed in this context",
+		},
+		"type error": {
+			body:          "name: my-group\nrules:\n  - record: metric:sum\n    expr: sum(metric)\n    labels: [a, b]\n",
+			expectedLine:  5,
+			expectedCol:   5,
+			expectedError: "unable to unmarshal response: line 5, column 5: cannot unmarshal !!seq into map[string]string",
+		},
+	}
+
+	for name, testData := range tests {
+		t.Run(name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, testData.body)
+			}))
+			defer ts.Close()
+
+			client, err := New(Config{Address: ts.URL, ID: "my-id"})
+			require.NoError(t, err)
+
+			_, err = client.GetRuleGroup(context.Background(), "my-namespace", "my-group")
+			parseErr := &ParseError{}
+			require.ErrorAs(t, err, &parseErr)
+			assert.Equal(t, testData.expectedLine, parseErr.Line)
+			assert.Equal(t, testData.expectedCol, parseErr.Column)
+			assert.EqualError(t, err, testData.expectedError)
+		})
+	}
+}
+
+func TestMimirClient_ListTenants(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, "/ruler/rule_groups", r.URL.Path)
+
+		// The ruler streams the rule groups tenant by tenant.
+		w.Header().Set("Content-Type", "application/yaml")
+		fmt.Fprint(w, `user2:
+    namespace1:
+        - name: group1
+          interval: 1m
+          rules:
+            - record: UP_RULE
+              expr: up
+user1:
+    namespace1:
+        - name: group1
+          rules:
+            - alert: UP_ALERT
+              expr: up < 1
+    namespace2:
+        - name: group1
+          rules:
+            - record: UP_RULE
+              expr: up
+`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	tenantIDs, err := client.ListTenants(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user1", "user2"}, tenantIDs)
+}
+
+func TestMimirClient_DrainNamespace(t *testing.T) {
+	var (
+		mtx            sync.Mutex
+		groups         = []string{"group-1", "group-2", "group-3"}
+		rateLimitedOne bool
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		switch r.Method {
+		case http.MethodGet:
+			require.Equal(t, "/api/v1/rules/my-namespace", r.URL.Path)
+			fmt.Fprintln(w, "my-namespace:")
+			for _, group := range groups {
+				fmt.Fprintf(w, "  - name: %s\n    rules: [{record: metric:sum, expr: sum(metric)}]\n", group)
+			}
+		case http.MethodDelete:
+			// The first deletion is rejected by the rate limits once.
+			if !rateLimitedOne {
+				rateLimitedOne = true
+				http.Error(w, "rate limited", http.StatusTooManyRequests)
+				return
+			}
+			for i, group := range groups {
+				if r.URL.Path == "/api/v1/rules/my-namespace/"+group {
+					groups = append(groups[:i], groups[i+1:]...)
+				}
+			}
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", MaxRetries: 1, DrainRate: 2})
+	require.NoError(t, err)
+	clk := newFakeClock()
+	client.clock = clk
+
+	type progress struct{ done, total int }
+	var reported []progress
+	require.NoError(t, client.DrainNamespace(context.Background(), "my-namespace", func(done, total int) {
+		reported = append(reported, progress{done, total})
+	}))
+
+	assert.Empty(t, groups)
+	assert.Equal(t, []progress{{1, 3}, {2, 3}, {3, 3}}, reported)
+
+	drainSleeps := 0
+	for _, d := range clk.Sleeps() {
+		if d == 500*time.Millisecond {
+			drainSleeps++
+		}
+	}
+	assert.Equal(t, 2, drainSleeps, "the deletions are paced by the drain rate")
+
+	// Draining a namespace without rule groups is a no-op.
+	require.NoError(t, client.DrainNamespace(context.Background(), "my-namespace", nil))
+}
+
+func TestMimirClient_ReplaceNamespace(t *testing.T) {
+	tests := map[string]struct {
+		groups           []rwrulefmt.RuleGroup
+		failingUpload    string
+		expectedRequests []string
+		expectedErr      string
+	}{
+		"orphan groups deleted once the desired groups are uploaded": {
+			groups: []rwrulefmt.RuleGroup{newTestRuleGroup("group-1", "metric:sum"), newTestRuleGroup("group-3", "metric:sum")},
+			expectedRequests: []string{
+				"GET /api/v1/rules/my-namespace",
+				"POST /api/v1/rules/my-namespace group-1",
+				"POST /api/v1/rules/my-namespace group-3",
+				"DELETE /api/v1/rules/my-namespace/group-2",
+			},
+		},
+		"nothing deleted if an upload fails": {
+			groups:        []rwrulefmt.RuleGroup{newTestRuleGroup("group-1", "metric:sum"), newTestRuleGroup("group-3", "metric:sum")},
+			failingUpload: "group-1",
+			expectedRequests: []string{
+				"GET /api/v1/rules/my-namespace",
+				"POST /api/v1/rules/my-namespace group-1",
+			},
+			expectedErr: "unable to upload rule group group-1",
+		},
+		"nothing sent if a group is invalid": {
+			groups:      []rwrulefmt.RuleGroup{newTestRuleGroup("group-1", "metric:sum"), newTestRuleGroup("")},
+			expectedErr: ErrEmptyRuleGroupName.Error(),
+		},
+		"nothing sent if a group is defined more than once": {
+			groups:      []rwrulefmt.RuleGroup{newTestRuleGroup("group-1", "metric:sum"), newTestRuleGroup("group-1", "metric:count")},
+			expectedErr: `rule group "group-1" is defined more than once`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var requests []string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodGet:
+					requests = append(requests, "GET "+r.URL.Path)
+					fmt.Fprint(w, "my-namespace:\n  - name: group-1\n    rules: []\n  - name: group-2\n    rules: []\n")
+				case http.MethodPost:
+					rg := rwrulefmt.RuleGroup{}
+					body, err := io.ReadAll(r.Body)
+					require.NoError(t, err)
+					require.NoError(t, yaml.Unmarshal(body, &rg))
+
+					requests = append(requests, "POST "+r.URL.Path+" "+rg.Name)
+					if rg.Name == testData.failingUpload {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					w.WriteHeader(http.StatusAccepted)
+				default:
+					requests = append(requests, r.Method+" "+r.URL.Path)
+					w.WriteHeader(http.StatusAccepted)
+				}
+			}))
+			defer ts.Close()
+
+			client, err := New(Config{Address: ts.URL, ID: "my-id"})
+			require.NoError(t, err)
+
+			err = client.ReplaceNamespace(context.Background(), "my-namespace", testData.groups)
+			if testData.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, testData.expectedRequests, requests)
+		})
+	}
+}
+
+func TestMimirClient_CreateRuleGroupWithSourceLabels(t *testing.T) {
+	bodyCh := make(chan []byte, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		bodyCh <- body
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{
+		Address:      ts.URL,
+		ID:           "my-id",
+		SourceLabels: map[string]string{"git_commit": "5e5dc9c", "source_file": "rules/my-namespace.yaml"},
+	})
+	require.NoError(t, err)
+
+	rg := newTestRuleGroup("my-group", "metric:sum", "metric:count")
+	rg.Rules[1].Labels = map[string]string{"source_file": "overridden.yaml", "team": "a"}
+	require.NoError(t, client.CreateRuleGroup(context.Background(), "my-namespace", rg))
+
+	posted := rwrulefmt.RuleGroup{}
+	require.NoError(t, yaml.Unmarshal(<-bodyCh, &posted))
+	require.Len(t, posted.Rules, 2)
+	assert.Equal(t, map[string]string{"git_commit": "5e5dc9c", "source_file": "rules/my-namespace.yaml"}, posted.Rules[0].Labels)
+	assert.Equal(t, map[string]string{"git_commit": "5e5dc9c", "source_file": "overridden.yaml", "team": "a"}, posted.Rules[1].Labels)
+
+	// The rule group of the caller is not modified.
+	assert.Nil(t, rg.Rules[0].Labels)
+	assert.Equal(t, map[string]string{"source_file": "overridden.yaml", "team": "a"}, rg.Rules[1].Labels)
+}
+
+func TestMimirClient_EstimateUploadSize(t *testing.T) {
+	received := atomic.NewInt64(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		received.Add(int64(len(body)))
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{
+		Address:      ts.URL,
+		ID:           "my-id",
+		SourceLabels: map[string]string{"source": "git"},
+	})
+	require.NoError(t, err)
+
+	groups := []rwrulefmt.RuleGroup{
+		newTestRuleGroup("group-1", "metric:sum"),
+		newTestRuleGroup("group-2", "metric:sum", "metric:max", "metric:min"),
+	}
+
+	estimate, err := client.EstimateUploadSize(groups)
+	require.NoError(t, err)
+
+	for _, rg := range groups {
+		require.NoError(t, client.CreateRuleGroup(context.Background(), "my-namespace", rg))
+	}
+	assert.InDelta(t, received.Load(), estimate, 0.01*float64(received.Load()))
+}
+
+func TestMimirClient_CreateRuleGroupWithRuleTransform(t *testing.T) {
+	var posted []rwrulefmt.RuleGroup
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		rg := rwrulefmt.RuleGroup{}
+		require.NoError(t, yaml.Unmarshal(body, &rg))
+		posted = append(posted, rg)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{
+		Address: ts.URL,
+		ID:      "my-id",
+		// The transform runs before the validation, so it can add the required labels.
+		RequireLabels: []string{"team"},
+		RuleTransform: func(rg rwrulefmt.RuleGroup) (rwrulefmt.RuleGroup, error) {
+			if rg.Name == "invalid" {
+				return rg, errors.New("unsupported rule group")
+			}
+
+			nodes := make([]rulefmt.RuleNode, 0, len(rg.Rules))
+			for _, rule := range rg.Rules {
+				labels := map[string]string{"team": "my-team"}
+				for name, value := range rule.Labels {
+					labels[name] = value
+				}
+				rule.Labels = labels
+				nodes = append(nodes, rule)
+			}
+			rg.Rules = nodes
+			return rg, nil
+		},
+	})
+	require.NoError(t, err)
+
+	rg := rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{
+		Name: "my-group",
+		Rules: []rulefmt.RuleNode{{
+			Alert:  yaml.Node{Kind: yaml.ScalarNode, Value: "HighErrorRate"},
+			Expr:   yaml.Node{Kind: yaml.ScalarNode, Value: "errors > 10"},
+			Labels: map[string]string{"severity": "critical"},
+		}},
+	}}
+	require.NoError(t, client.CreateRuleGroup(context.Background(), "my-namespace", rg))
+	require.Len(t, posted, 1)
+	assert.Equal(t, map[string]string{"team": "my-team", "severity": "critical"}, posted[0].Rules[0].Labels)
+
+	// The input rule group is not modified.
+	assert.Equal(t, map[string]string{"severity": "critical"}, rg.Rules[0].Labels)
+
+	rg.Name = "invalid"
+	err = client.CreateRuleGroup(context.Background(), "my-namespace", rg)
+	require.EqualError(t, err, "unable to transform rule group invalid: unsupported rule group")
+	assert.Len(t, posted, 1)
+}
+
+// newTestRuleGroup returns a rule group with a recording rule for each of the given names.
+// captureStdout returns what f writes to stdout.
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	require.NoError(t, w.Close())
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func newTestRuleGroup(name string, records ...string) rwrulefmt.RuleGroup {
+	rg := rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: name}}
+	for _, record := range records {
+		rg.Rules = append(rg.Rules, rulefmt.RuleNode{
+			Record: yaml.Node{Kind: yaml.ScalarNode, Value: record},
+			Expr:   yaml.Node{Kind: yaml.ScalarNode, Value: "sum(metric)"},
+		})
+	}
+	return rg
+}
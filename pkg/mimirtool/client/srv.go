@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// srvAddressPrefix is the prefix of the addresses resolved through DNS SRV records,
+// for example srv+_http._tcp.ruler.svc.
+const srvAddressPrefix = "srv+"
+
+// srvRefreshInterval is how often the SRV records are resolved again.
+var srvRefreshInterval = 30 * time.Second
+
+// srvResolver resolves DNS SRV records. It's implemented by net.Resolver.
+type srvResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// srvEndpoints balances the requests across the targets of a DNS SRV record, in a
+// round-robin fashion. Only the targets with the lowest priority are used.
+type srvEndpoints struct {
+	name     string
+	resolver srvResolver
+	logger   *log.Logger
+
+	mtx       sync.Mutex
+	targets   []string
+	refreshAt time.Time
+	next      int
+}
+
+// parseSRVAddress returns the URL scheme and the SRV record name of an address with the
+// srv+ prefix. The scheme is https for the _https services, http otherwise.
+func parseSRVAddress(address string) (scheme, name string, ok bool) {
+	if !strings.HasPrefix(address, srvAddressPrefix) {
+		return "", "", false
+	}
+
+	name = strings.TrimPrefix(address, srvAddressPrefix)
+	if strings.HasPrefix(name, "_https.") {
+		return "https", name, true
+	}
+	return "http", name, true
+}
+
+// host returns the host and port of the target the next request should be sent to.
+// The SRV record is resolved again once the refresh interval is elapsed. If it fails,
+// the previously resolved targets keep being used.
+func (e *srvEndpoints) host(ctx context.Context, now time.Time) (string, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if len(e.targets) == 0 || !now.Before(e.refreshAt) {
+		targets, err := e.resolve(ctx)
+		switch {
+		case err == nil:
+			e.targets = targets
+			e.refreshAt = now.Add(srvRefreshInterval)
+		case len(e.targets) == 0:
+			return "", err
+		default:
+			e.logger.WithError(err).WithField("name", e.name).Warnln("unable to resolve the SRV record, using the previously resolved targets")
+		}
+	}
+
+	host := e.targets[e.next%len(e.targets)]
+	e.next++
+	return host, nil
+}
+
+func (e *srvEndpoints) resolve(ctx context.Context) ([]string, error) {
+	_, records, err := e.resolver.LookupSRV(ctx, "", "", e.name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to resolve SRV record %s", e.name)
+	}
+	if len(records) == 0 {
+		return nil, errors.Errorf("no target in SRV record %s", e.name)
+	}
+
+	minPriority := records[0].Priority
+	for _, record := range records {
+		if record.Priority < minPriority {
+			minPriority = record.Priority
+		}
+	}
+
+	var targets []string
+	for _, record := range records {
+		if record.Priority == minPriority {
+			targets = append(targets, net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port))))
+		}
+	}
+	sort.Strings(targets)
+
+	return targets, nil
+}
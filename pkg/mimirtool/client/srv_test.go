@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubSRVResolver returns the configured records, or err if set.
+type stubSRVResolver struct {
+	mtx     sync.Mutex
+	records []*net.SRV
+	err     error
+	lookups int
+}
+
+func (r *stubSRVResolver) LookupSRV(_ context.Context, _, _, _ string) (string, []*net.SRV, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.lookups++
+	return "", r.records, r.err
+}
+
+func TestMimirClient_SRVAddress(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		requests = map[string]int{}
+	)
+	newServer := func(name string) *net.SRV {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			requests[name]++
+		}))
+		t.Cleanup(ts.Close)
+
+		u, err := url.Parse(ts.URL)
+		require.NoError(t, err)
+		port, err := strconv.Atoi(u.Port())
+		require.NoError(t, err)
+
+		return &net.SRV{Target: u.Hostname() + ".", Port: uint16(port)}
+	}
+
+	resolver := &stubSRVResolver{records: []*net.SRV{newServer("ruler-1"), newServer("ruler-2")}}
+
+	client, err := New(Config{Address: "srv+_http._tcp.ruler.svc", ID: "my-id"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://_http._tcp.ruler.svc", client.Endpoint())
+
+	clock := newFakeClock()
+	client.clock = clock
+	client.srv.resolver = resolver
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group"))
+	}
+	assert.Equal(t, map[string]int{"ruler-1": 2, "ruler-2": 2}, requests)
+	assert.Equal(t, 1, resolver.lookups)
+
+	// Once the refresh interval is elapsed, the record is resolved again, and previously
+	// resolved targets keep being used if it fails.
+	_ = clock.Sleep(context.Background(), srvRefreshInterval)
+	resolver.err = errors.New("no such host")
+
+	require.NoError(t, client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group"))
+	assert.Equal(t, 2, resolver.lookups)
+	assert.Equal(t, 5, requests["ruler-1"]+requests["ruler-2"])
+
+	t.Run("resolution failing without targets", func(t *testing.T) {
+		client, err := New(Config{Address: "srv+_http._tcp.ruler.svc", ID: "my-id"})
+		require.NoError(t, err)
+		client.srv.resolver = &stubSRVResolver{err: errors.New("no such host")}
+
+		err = client.DeleteRuleGroup(context.Background(), "my-namespace", "my-group")
+		require.EqualError(t, err, "unable to resolve SRV record _http._tcp.ruler.svc: no such host")
+	})
+}
+
+func TestParseSRVAddress(t *testing.T) {
+	for address, expected := range map[string]struct {
+		scheme, name string
+		ok           bool
+	}{
+		"srv+_http._tcp.ruler.svc":  {scheme: "http", name: "_http._tcp.ruler.svc", ok: true},
+		"srv+_https._tcp.ruler.svc": {scheme: "https", name: "_https._tcp.ruler.svc", ok: true},
+		"http://ruler.svc":          {},
+	} {
+		scheme, name, ok := parseSRVAddress(address)
+		assert.Equal(t, expected.scheme, scheme, address)
+		assert.Equal(t, expected.name, name, address)
+		assert.Equal(t, expected.ok, ok, address)
+	}
+}
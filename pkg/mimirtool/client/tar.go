@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/grafana/dskit/multierror"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules"
+)
+
+// gzipMagic is the header identifying gzip compressed content.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// LoadRuleGroupsFromTar uploads the rule groups of the namespace files contained in the
+// tar archive read from r, which can optionally be gzip compressed. The archive is
+// streamed, so it doesn't need to be extracted first: each entry is parsed and its rule
+// groups uploaded before the next one is read. Each YAML file is a namespace, named after
+// the file unless the namespace is explicitly set in its content, and without the prefix
+// configured by Config.StripNamespacePrefix. Any other entry is skipped. Reading stops at
+// the first invalid namespace file, once the rule groups of the previous entries have
+// been uploaded. Rule groups failing to upload are reported in a MultiError, as
+// "<namespace>/<group>". If set, onProgress is called after each rule group, with an
+// unknown total since the archive isn't read upfront. The rule groups recorded in the
+// journal configured by Config.JournalPath are skipped.
+func LoadRuleGroupsFromTar(ctx context.Context, client *MimirClient, r io.Reader, onProgress ProgressFunc) error {
+	j, err := client.openJournal()
+	if err != nil {
+		return err
+	}
+
+	err = loadRuleGroupsFromTar(ctx, client, j, r, onProgress)
+	if closeErr := j.close(err == nil); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// importedNamespace returns the name a namespace is imported as, without the prefix
+// configured by StripNamespacePrefix. A namespace named as the prefix is kept as is.
+func (r *MimirClient) importedNamespace(namespace string) string {
+	if r.stripNamespacePrefix == "" || namespace == r.stripNamespacePrefix {
+		return namespace
+	}
+	return strings.TrimPrefix(namespace, r.stripNamespacePrefix)
+}
+
+func loadRuleGroupsFromTar(ctx context.Context, client *MimirClient, j *journal, r io.Reader, onProgress ProgressFunc) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(len(gzipMagic)); err == nil && bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return errors.Wrap(err, "unable to read gzip compressed archive")
+		}
+		defer gz.Close()
+
+		r = gz
+	} else {
+		r = br
+	}
+
+	done := 0
+	errs := &MultiError{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return errs.Err()
+		}
+		if err != nil {
+			return errors.Wrap(err, "unable to read archive")
+		}
+
+		ext := path.Ext(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || (ext != ".yaml" && ext != ".yml") {
+			client.logger.WithField("entry", hdr.Name).Debugln("skipping archive entry")
+			continue
+		}
+
+		nss, parseErrs := rules.ParseReader(tr)
+		if len(parseErrs) > 0 {
+			merr := multierror.New(parseErrs...)
+			return errors.Wrapf(merr.Err(), "unable to parse archive entry %s", hdr.Name)
+		}
+
+		for _, ns := range nss {
+			namespace := ns.Namespace
+			if namespace == "" {
+				namespace = strings.TrimSuffix(path.Base(hdr.Name), ext)
+			}
+			namespace = client.importedNamespace(namespace)
+
+			for _, group := range ns.Groups {
+				id := journalID(client.requestTenantID(ctx), namespace, group.Name)
+				if j.done(id) {
+					client.logger.WithField("group", namespace+"/"+group.Name).Debugln("skipping rule group recorded in the journal")
+				} else if err := client.CreateRuleGroup(ctx, namespace, group); err != nil {
+					errs.Add(namespace+"/"+group.Name, errors.Wrapf(err, "unable to load rule group from archive entry %s", hdr.Name))
+					if client.stopOnFirstError {
+						return errs.Err()
+					}
+				} else if err := j.record(id); err != nil {
+					return err
+				}
+				done++
+				onProgress.report(done, -1)
+			}
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+func TestLoadRuleGroupsFromTar(t *testing.T) {
+	var (
+		uploadsMtx sync.Mutex
+		uploads    = map[string][]string{}
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		rg := rwrulefmt.RuleGroup{}
+		require.NoError(t, yaml.Unmarshal(body, &rg))
+
+		uploadsMtx.Lock()
+		defer uploadsMtx.Unlock()
+		uploads[r.URL.Path] = append(uploads[r.URL.Path], rg.Name)
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	entries := []struct {
+		name     string
+		typeflag byte
+		content  string
+	}{
+		{name: "rules/", typeflag: tar.TypeDir},
+		{name: "rules/namespace-1.yaml", typeflag: tar.TypeReg, content: `
+groups:
+  - name: group-1
+    rules:
+      - record: metric:sum
+        expr: sum(metric)
+  - name: group-2
+    rules:
+      - record: metric:count
+        expr: count(metric)
+`},
+		{name: "rules/other.yml", typeflag: tar.TypeReg, content: `
+namespace: namespace-2
+groups:
+  - name: group-3
+    rules:
+      - alert: HighErrorRate
+        expr: errors > 10
+`},
+		{name: "rules/README.md", typeflag: tar.TypeReg, content: "not rules"},
+	}
+
+	for _, compressed := range []bool{false, true} {
+		uploads = map[string][]string{}
+
+		buf := bytes.Buffer{}
+		var w io.WriteCloser = nopWriteCloser{&buf}
+		if compressed {
+			w = gzip.NewWriter(&buf)
+		}
+
+		tw := tar.NewWriter(w)
+		for _, entry := range entries {
+			require.NoError(t, tw.WriteHeader(&tar.Header{
+				Name:     entry.name,
+				Typeflag: entry.typeflag,
+				Mode:     0644,
+				Size:     int64(len(entry.content)),
+			}))
+			_, err := tw.Write([]byte(entry.content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		require.NoError(t, w.Close())
+
+		require.NoError(t, LoadRuleGroupsFromTar(context.Background(), client, &buf, nil))
+		assert.Equal(t, map[string][]string{
+			"/api/v1/rules/namespace-1": {"group-1", "group-2"},
+			"/api/v1/rules/namespace-2": {"group-3"},
+		}, uploads, "compressed: %t", compressed)
+	}
+}
+
+func TestLoadRuleGroupsFromTar_InvalidEntry(t *testing.T) {
+	buf := bytes.Buffer{}
+	tw := tar.NewWriter(&buf)
+	content := "groups: [this is not valid"
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "invalid.yaml", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+	_, err := tw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	client, err := New(Config{Address: "http://localhost", ID: "my-id"})
+	require.NoError(t, err)
+
+	err = LoadRuleGroupsFromTar(context.Background(), client, &buf, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid.yaml")
+}
+
+func TestLoadRuleGroupsFromTar_ResolvesAnchors(t *testing.T) {
+	bodiesCh := make(chan string, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		bodiesCh <- string(body)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	content := `
+groups:
+  - name: group-1
+    rules:
+      - alert: InstanceDown
+        expr: up == 0
+        annotations: &common
+          runbook_url: https://runbooks.example.com
+  - name: group-2
+    rules:
+      - alert: JobDown
+        expr: &expr absent(up)
+        annotations: *common
+      - alert: JobDownForLong
+        expr: *expr
+        for: 1h
+        annotations:
+          <<: *common
+          summary: Job is down for long
+`
+	buf := bytes.Buffer{}
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "namespace.yaml", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+	_, err = tw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	require.NoError(t, LoadRuleGroupsFromTar(context.Background(), client, &buf, nil))
+	<-bodiesCh
+	assert.Equal(t, `name: group-2
+rules:
+    - alert: JobDown
+      expr: absent(up)
+      annotations:
+        runbook_url: https://runbooks.example.com
+    - alert: JobDownForLong
+      expr: absent(up)
+      for: 1h
+      annotations:
+        runbook_url: https://runbooks.example.com
+        summary: Job is down for long
+`, <-bodiesCh)
+}
+
+func TestLoadRuleGroupsFromTar_Streamed(t *testing.T) {
+	uploadsCh := make(chan string, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploadsCh <- r.URL.Path
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- LoadRuleGroupsFromTar(context.Background(), client, pr, nil)
+	}()
+
+	tw := tar.NewWriter(pw)
+	writeEntry := func(name string) {
+		content := "groups:\n  - name: group\n    rules:\n      - record: metric:sum\n        expr: sum(metric)\n"
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, tw.Flush())
+	}
+
+	// The first entry is uploaded before the next one is written, so the archive
+	// isn't read in full first.
+	writeEntry("namespace-1.yaml")
+	assert.Equal(t, "/api/v1/rules/namespace-1", <-uploadsCh)
+
+	writeEntry("namespace-2.yaml")
+	require.NoError(t, tw.Close())
+	require.NoError(t, pw.Close())
+
+	require.NoError(t, <-errCh)
+	assert.Equal(t, "/api/v1/rules/namespace-2", <-uploadsCh)
+}
+
+func TestLoadRuleGroupsFromTar_StripNamespacePrefix(t *testing.T) {
+	var (
+		uploadsMtx sync.Mutex
+		uploads    []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploadsMtx.Lock()
+		defer uploadsMtx.Unlock()
+		uploads = append(uploads, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", StripNamespacePrefix: "tenant-1-"})
+	require.NoError(t, err)
+
+	buf := bytes.Buffer{}
+	tw := tar.NewWriter(&buf)
+	for name, namespace := range map[string]string{
+		"tenant-1-namespace-1.yaml": "",
+		"explicit.yaml":             "tenant-1-namespace-2",
+		"other.yaml":                "tenant-2-namespace-3",
+		"suffix.yaml":               "namespace-4-tenant-1-",
+		"prefix.yaml":               "tenant-1-",
+	} {
+		content := "groups:\n  - name: group\n    rules:\n      - record: metric:sum\n        expr: sum(metric)\n"
+		if namespace != "" {
+			content = "namespace: " + namespace + "\n" + content
+		}
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	require.NoError(t, LoadRuleGroupsFromTar(context.Background(), client, &buf, nil))
+	assert.ElementsMatch(t, []string{
+		"/api/v1/rules/namespace-1",
+		"/api/v1/rules/namespace-2",
+		"/api/v1/rules/tenant-2-namespace-3",
+		"/api/v1/rules/namespace-4-tenant-1-",
+		"/api/v1/rules/tenant-1-",
+	}, uploads)
+}
+
+func TestLoadRuleGroupsFromTar_StopOnFirstError(t *testing.T) {
+	buf := bytes.Buffer{}
+	tw := tar.NewWriter(&buf)
+	content := "groups:\n"
+	for _, name := range []string{"group-1", "group-2", "group-3", "group-4"} {
+		content += "  - name: " + name + "\n    rules:\n      - record: metric:sum\n        expr: sum(metric)\n"
+	}
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "namespace.yaml", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+	_, err := tw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	for _, stopOnFirstError := range []bool{false, true} {
+		var (
+			uploadsMtx sync.Mutex
+			uploads    int
+		)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			uploadsMtx.Lock()
+			defer uploadsMtx.Unlock()
+			uploads++
+			if uploads == 2 {
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+		}))
+
+		client, err := New(Config{Address: ts.URL, ID: "my-id", StopOnFirstError: stopOnFirstError})
+		require.NoError(t, err)
+
+		err = LoadRuleGroupsFromTar(context.Background(), client, bytes.NewReader(buf.Bytes()), nil)
+		ts.Close()
+
+		merr := &MultiError{}
+		require.ErrorAs(t, err, &merr)
+		require.Len(t, merr.Errors, 1)
+		assert.Equal(t, "namespace/group-2", merr.Errors[0].Item)
+
+		if stopOnFirstError {
+			assert.Equal(t, 2, uploads)
+		} else {
+			assert.Equal(t, 4, uploads)
+		}
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
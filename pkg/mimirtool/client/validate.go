@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules"
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+// ValidationReport is the result of the validation of the namespace files of a directory.
+type ValidationReport struct {
+	Files  int
+	Groups int
+	Errors []ValidationError
+}
+
+// Valid returns whether no validation error was found.
+func (r ValidationReport) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// ValidationError is a validation error of a namespace file or, if Group is set, of one
+// of its rule groups.
+type ValidationError struct {
+	File  string
+	Group string
+	Err   error
+}
+
+func (e ValidationError) Error() string {
+	if e.Group == "" {
+		return e.File + ": " + e.Err.Error()
+	}
+	return e.File + ": rule group " + e.Group + ": " + e.Err.Error()
+}
+
+// ValidationWarning reports a rule which is accepted, but likely misconfigured.
+type ValidationWarning struct {
+	Group string
+	Rule  string
+	Msg   string
+}
+
+func (w ValidationWarning) String() string {
+	return fmt.Sprintf("rule %q of rule group %q: %s", w.Rule, w.Group, w.Msg)
+}
+
+// ruleGroupWarnings returns the warnings about the rules of the rule group, as sent to
+// the server. An alerting rule with a for duration lower than the evaluation interval
+// is reported, as the alerts can only fire on an evaluation, so the for duration is
+// actually rounded up to the evaluation interval. The rule groups without an evaluation
+// interval are not checked, as they use the default of the server.
+func ruleGroupWarnings(rg rwrulefmt.RuleGroup) []ValidationWarning {
+	interval := time.Duration(rg.Interval)
+	if interval == 0 {
+		return nil
+	}
+
+	var warnings []ValidationWarning
+	for _, rule := range rg.Rules {
+		if rule.Alert.Value == "" || rule.For == 0 || time.Duration(rule.For) >= interval {
+			continue
+		}
+		warnings = append(warnings, ValidationWarning{
+			Group: rg.Name,
+			Rule:  rule.Alert.Value,
+			Msg:   fmt.Sprintf("the for duration %s is lower than the evaluation interval %s of the rule group", rule.For, model.Duration(interval)),
+		})
+	}
+	return warnings
+}
+
+// ValidateDirOptions configures how ValidateDirWithOptions validates the rule groups.
+type ValidateDirOptions struct {
+	// Remote also sends each rule group passing the client checks to the server, with
+	// ValidateOnly, so that the checks the server runs on upload, such as the limits of
+	// the tenant, are covered too. Nothing is stored by the server. The rule groups are
+	// sent to the namespace of their file, as by mimirtool rules load.
+	Remote bool
+}
+
+// ValidateDir validates the namespace files found in dir and its subdirectories, without
+// uploading anything. Each file is validated as a Prometheus rule file, and each of its
+// rule groups against the client checks run by CreateRuleGroup, such as the required
+// labels and the rule transform. The validation is local only, so it doesn't need the
+// server, and the checks the server only runs on upload are not covered: they can be
+// with ValidateDirWithOptions. The validation errors are reported in the
+// ValidationReport, while an error is returned if the directory can't be read.
+func (r *MimirClient) ValidateDir(ctx context.Context, dir string) (ValidationReport, error) {
+	return r.ValidateDirWithOptions(ctx, dir, ValidateDirOptions{})
+}
+
+// ValidateDirWithOptions validates the namespace files found in dir and its
+// subdirectories like ValidateDir, with the given options. The errors returned by the
+// server are reported in the ValidationReport as the client ones.
+func (r *MimirClient) ValidateDirWithOptions(ctx context.Context, dir string, opts ValidateDirOptions) (ValidationReport, error) {
+	report := ValidationReport{}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ext := filepath.Ext(path)
+		if d.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			return nil
+		}
+
+		report.Files++
+		nss, errs := rules.Parse(path)
+		for _, err := range errs {
+			report.Errors = append(report.Errors, ValidationError{File: path, Err: err})
+		}
+
+		for _, ns := range nss {
+			for _, group := range ns.Groups {
+				report.Groups++
+				if _, _, err := r.ruleGroupPayload(group); err != nil {
+					report.Errors = append(report.Errors, ValidationError{File: path, Group: group.Name, Err: err})
+					continue
+				}
+
+				if opts.Remote {
+					namespace := ns.Namespace
+					if namespace == "" {
+						namespace = strings.TrimSuffix(filepath.Base(path), ext)
+					}
+					if _, _, err := r.createRuleGroup(ctx, namespace, group, CreateOptions{ValidateOnly: true}); err != nil {
+						report.Errors = append(report.Errors, ValidationError{File: path, Group: group.Name, Err: err})
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ValidationReport{}, errors.Wrapf(err, "unable to validate directory %s", dir)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+func TestMimirClient_ValidateDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"valid.yaml": `
+groups:
+  - name: valid
+    rules:
+      - alert: HighErrorRate
+        expr: errors > 10
+        labels:
+          severity: critical
+`,
+		"invalid-expr.yaml": `
+groups:
+  - name: invalid-expr
+    rules:
+      - record: metric:sum
+        expr: sum(metric
+`,
+		"team/missing-label.yml": `
+groups:
+  - name: missing-label
+    rules:
+      - alert: HighLatency
+        expr: latency > 1
+  - name: also-valid
+    rules:
+      - record: metric:sum
+        expr: sum(metric)
+`,
+		"README.md": "not rules",
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	// The server is not reachable: nothing is uploaded.
+	client, err := New(Config{Address: "http://127.0.0.1:0", ID: "my-id", RequireLabels: []string{"severity"}})
+	require.NoError(t, err)
+
+	report, err := client.ValidateDir(context.Background(), dir)
+	require.NoError(t, err)
+	assert.False(t, report.Valid())
+	assert.Equal(t, 3, report.Files)
+	assert.Equal(t, 3, report.Groups)
+
+	require.Len(t, report.Errors, 2)
+	assert.Equal(t, filepath.Join(dir, "invalid-expr.yaml"), report.Errors[0].File)
+	assert.Empty(t, report.Errors[0].Group)
+	assert.Equal(t, filepath.Join(dir, "team/missing-label.yml"), report.Errors[1].File)
+	assert.Equal(t, "missing-label", report.Errors[1].Group)
+	assert.EqualError(t, report.Errors[1], filepath.Join(dir, "team/missing-label.yml")+`: rule group missing-label: rule group "missing-label" has alerting rules missing required fields: HighLatency (missing label severity)`)
+
+	t.Run("missing directory", func(t *testing.T) {
+		_, err := client.ValidateDir(context.Background(), filepath.Join(dir, "missing"))
+		require.Error(t, err)
+	})
+
+	t.Run("remote", func(t *testing.T) {
+		var (
+			mtx       sync.Mutex
+			validated []string
+		)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "true", r.URL.Query().Get("validate_only"))
+			rg := rwrulefmt.RuleGroup{}
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.NoError(t, yaml.Unmarshal(body, &rg))
+
+			mtx.Lock()
+			validated = append(validated, r.URL.Path+"/"+rg.Name)
+			mtx.Unlock()
+
+			if rg.Name == "also-valid" {
+				http.Error(w, "per-user rule groups limit (limit: 1 actual: 2) exceeded", http.StatusBadRequest)
+			}
+		}))
+		defer ts.Close()
+
+		client, err := New(Config{Address: ts.URL, ID: "my-id", RequireLabels: []string{"severity"}})
+		require.NoError(t, err)
+
+		report, err := client.ValidateDirWithOptions(context.Background(), dir, ValidateDirOptions{Remote: true})
+		require.NoError(t, err)
+
+		// Only the rule groups passing the client checks are sent, to the namespace of their file.
+		assert.ElementsMatch(t, []string{"/api/v1/rules/valid/valid", "/api/v1/rules/missing-label/also-valid"}, validated)
+
+		require.Len(t, report.Errors, 3)
+		assert.Equal(t, filepath.Join(dir, "team/missing-label.yml"), report.Errors[2].File)
+		assert.Equal(t, "also-valid", report.Errors[2].Group)
+		assert.Contains(t, report.Errors[2].Error(), "per-user rule groups limit")
+	})
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rules
+
+import (
+	"strings"
+
+	"github.com/prometheus/prometheus/promql/parser"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+// DependencyWarning reports a rule whose expression references a recording rule that is
+// not defined.
+type DependencyWarning struct {
+	Group  string
+	Rule   string
+	Metric string
+}
+
+// AnalyzeRuleDependencies returns a warning for each metric referenced by the rules of
+// the groups that looks like a recording rule, i.e. which name contains a colon as per
+// the Prometheus naming conventions, but is not recorded by any rule of the groups.
+// Expressions that fail to parse are skipped, so the analysis is best-effort.
+func AnalyzeRuleDependencies(groups []rwrulefmt.RuleGroup) []DependencyWarning {
+	recorded := map[string]struct{}{}
+	for _, group := range groups {
+		for _, rule := range group.Rules {
+			if rule.Record.Value != "" {
+				recorded[rule.Record.Value] = struct{}{}
+			}
+		}
+	}
+
+	var warnings []DependencyWarning
+	for _, group := range groups {
+		for _, rule := range group.Rules {
+			expr, err := parser.ParseExpr(rule.Expr.Value)
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{
+					"group": group.Name,
+					"rule":  getRuleName(rule),
+				}).Debugln("skipping the dependencies of a rule failing to parse")
+				continue
+			}
+
+			seen := map[string]struct{}{}
+			parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+				n, ok := node.(*parser.VectorSelector)
+				if !ok || !strings.Contains(n.Name, ":") {
+					return nil
+				}
+				if _, ok := recorded[n.Name]; ok {
+					return nil
+				}
+				if _, ok := seen[n.Name]; ok {
+					return nil
+				}
+				seen[n.Name] = struct{}{}
+
+				warnings = append(warnings, DependencyWarning{
+					Group:  group.Name,
+					Rule:   getRuleName(rule),
+					Metric: n.Name,
+				})
+				return nil
+			})
+		}
+	}
+
+	return warnings
+}
@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+func TestAnalyzeRuleDependencies(t *testing.T) {
+	groups := []rwrulefmt.RuleGroup{
+		{RuleGroup: rulefmt.RuleGroup{Name: "recording", Rules: []rulefmt.RuleNode{
+			{Record: yaml.Node{Value: "job:requests:rate5m"}, Expr: yaml.Node{Value: "sum by (job) (rate(requests_total[5m]))"}},
+		}}},
+		{RuleGroup: rulefmt.RuleGroup{Name: "alerting", Rules: []rulefmt.RuleNode{
+			// Recorded by a rule of another group.
+			{Alert: yaml.Node{Value: "HighRequestRate"}, Expr: yaml.Node{Value: "job:requests:rate5m > 100"}},
+			// The rule recording job:errors:rate5m doesn't exist anymore.
+			{Alert: yaml.Node{Value: "HighErrorRate"}, Expr: yaml.Node{Value: "job:errors:rate5m / job:requests:rate5m > 0.1 or job:errors:rate5m > 10"}},
+			// Raw metrics are not reported.
+			{Alert: yaml.Node{Value: "Down"}, Expr: yaml.Node{Value: "up == 0"}},
+			// Invalid expressions are skipped.
+			{Alert: yaml.Node{Value: "Invalid"}, Expr: yaml.Node{Value: "sum(missing:metric"}},
+		}}},
+	}
+
+	assert.Equal(t, []DependencyWarning{
+		{Group: "alerting", Rule: "HighErrorRate", Metric: "job:errors:rate5m"},
+	}, AnalyzeRuleDependencies(groups))
+}
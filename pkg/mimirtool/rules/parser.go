@@ -81,10 +81,18 @@ func Parse(f string) ([]RuleNamespace, []error) {
 }
 
 func ParseBytes(content []byte) ([]RuleNamespace, []error) {
-	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	return ParseReader(bytes.NewReader(content))
+}
+
+// ParseReader parses and validates the rules read from r. The rules can be split across
+// several YAML documents: the rule groups of the documents of the same namespace are
+// merged, in order, and the empty documents are ignored.
+func ParseReader(r io.Reader) ([]RuleNamespace, []error) {
+	decoder := yaml.NewDecoder(r)
 	decoder.KnownFields(true)
 
 	var nss []RuleNamespace
+	indexes := map[string]int{}
 	for {
 		var ns RuleNamespace
 		err := decoder.Decode(&ns)
@@ -94,16 +102,45 @@ func ParseBytes(content []byte) ([]RuleNamespace, []error) {
 		if err != nil {
 			return nil, []error{err}
 		}
+		if ns.Namespace == "" && len(ns.Groups) == 0 {
+			continue
+		}
+
+		resolveAliases(&ns)
+
+		if i, ok := indexes[ns.Namespace]; ok {
+			nss[i].Groups = append(nss[i].Groups, ns.Groups...)
+			continue
+		}
+		indexes[ns.Namespace] = len(nss)
+		nss = append(nss, ns)
+	}
 
+	for _, ns := range nss {
 		if errs := ns.Validate(); len(errs) > 0 {
 			return nil, errs
 		}
-
-		nss = append(nss, ns)
 	}
 	return nss, nil
 }
 
+// resolveAliases replaces the YAML aliases in the rules with the nodes they refer to, and
+// drops the anchors, so that each rule group is self-contained when marshalled on its own.
+// Labels and annotations don't need it, because aliases are resolved when decoding maps.
+func resolveAliases(ns *RuleNamespace) {
+	for i := range ns.Groups {
+		for j := range ns.Groups[i].Rules {
+			rule := &ns.Groups[i].Rules[j]
+			for _, node := range []*yaml.Node{&rule.Record, &rule.Alert, &rule.Expr} {
+				for node.Kind == yaml.AliasNode && node.Alias != nil {
+					*node = *node.Alias
+				}
+				node.Anchor = ""
+			}
+		}
+	}
+}
+
 func loadFile(filename string) ([]byte, error) {
 	file, err := os.Open(filename)
 	if err != nil {
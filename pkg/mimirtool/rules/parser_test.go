@@ -10,6 +10,9 @@ import (
 	"testing"
 
 	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
 
 	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
 )
@@ -94,6 +97,51 @@ func TestParseFiles(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:    "multiple_documents_file",
+			backend: MimirBackend,
+			files: []string{
+				"testdata/multiple_documents.yaml",
+			},
+			want: map[string]RuleNamespace{
+				"multiple_documents": {
+					Namespace: "multiple_documents",
+					Groups: []rwrulefmt.RuleGroup{
+						{
+							RuleGroup: rulefmt.RuleGroup{
+								Name:  "first_rule_group",
+								Rules: []rulefmt.RuleNode{{}},
+							},
+						},
+						{
+							RuleGroup: rulefmt.RuleGroup{
+								Name:  "second_rule_group",
+								Rules: []rulefmt.RuleNode{{}, {}},
+							},
+						},
+					},
+				},
+				"other_example_namespace": {
+					Namespace: "other_example_namespace",
+					Groups: []rwrulefmt.RuleGroup{
+						{
+							RuleGroup: rulefmt.RuleGroup{
+								Name:  "other_rule_group",
+								Rules: []rulefmt.RuleNode{{}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:    "multiple_documents_repeated_group",
+			backend: MimirBackend,
+			files: []string{
+				"testdata/multiple_documents_repeated_group.yaml",
+			},
+			wantErr: true,
+		},
 		{
 			name:    "federated_rule_groups",
 			backend: MimirBackend,
@@ -181,3 +229,32 @@ func compareNamespace(g, w RuleNamespace) error {
 
 	return nil
 }
+
+func TestParseFiles_ResolvesAnchors(t *testing.T) {
+	ruleSet, err := ParseFiles(MimirBackend, []string{"testdata/anchors_namespace.yaml"})
+	require.NoError(t, err)
+
+	groups := ruleSet["anchors_namespace"].Groups
+	require.Len(t, groups, 2)
+
+	// Each rule group is marshalled on its own when uploaded, so it must not refer
+	// to the anchors defined in other rule groups.
+	out, err := yaml.Marshal(&groups[1])
+	require.NoError(t, err)
+	assert.Equal(t, `name: second_rule_group
+rules:
+    - alert: InstanceDown
+      expr: up == 0
+      labels:
+        severity: critical
+        team: platform
+      annotations:
+        runbook_url: https://runbooks.example.com/instance-down
+        summary: Instance is down
+`, string(out))
+
+	// The anchors are dropped from the rule group defining them.
+	out, err = yaml.Marshal(&groups[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "&")
+}
@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/dskit/multierror"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+// prometheusVersion is a Prometheus version, as major, minor and patch numbers.
+type prometheusVersion [3]int
+
+func parsePrometheusVersion(version string) (prometheusVersion, error) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+
+	var v prometheusVersion
+	for i, part := range parts {
+		// Ignore the pre-release and build metadata, for example 2.30.0-rc.0.
+		if i == len(parts)-1 {
+			if idx := strings.IndexAny(part, "-+"); idx >= 0 {
+				part = part[:idx]
+			}
+		}
+
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return prometheusVersion{}, fmt.Errorf("invalid Prometheus version %q", version)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+func (v prometheusVersion) less(o prometheusVersion) bool {
+	for i := range v {
+		if v[i] != o[i] {
+			return v[i] < o[i]
+		}
+	}
+	return false
+}
+
+func (v prometheusVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// functionVersions are the Prometheus versions the PromQL functions have been added in,
+// for the functions added after 2.0.0.
+var functionVersions = map[string]prometheusVersion{
+	"absent_over_time":  {2, 16, 0},
+	"last_over_time":    {2, 26, 0},
+	"sgn":               {2, 26, 0},
+	"clamp":             {2, 26, 0},
+	"acos":              {2, 26, 0},
+	"acosh":             {2, 26, 0},
+	"asin":              {2, 26, 0},
+	"asinh":             {2, 26, 0},
+	"atan":              {2, 26, 0},
+	"atanh":             {2, 26, 0},
+	"cos":               {2, 26, 0},
+	"cosh":              {2, 26, 0},
+	"sin":               {2, 26, 0},
+	"sinh":              {2, 26, 0},
+	"tan":               {2, 26, 0},
+	"tanh":              {2, 26, 0},
+	"deg":               {2, 26, 0},
+	"rad":               {2, 26, 0},
+	"pi":                {2, 26, 0},
+	"present_over_time": {2, 29, 0},
+}
+
+var (
+	subqueryVersion       = prometheusVersion{2, 7, 0}
+	groupVersion          = prometheusVersion{2, 17, 0}
+	atModifierVersion     = prometheusVersion{2, 25, 0}
+	negativeOffsetVersion = prometheusVersion{2, 26, 0}
+	atan2Version          = prometheusVersion{2, 26, 0}
+)
+
+// ValidateRuleGroupForVersion returns an error if an expression of the rule group doesn't
+// parse, or uses PromQL features not supported by the given Prometheus version, such as
+// functions or modifiers added in later versions. The expressions are parsed with the
+// vendored Prometheus parser, so the features removed in later versions are not detected.
+func ValidateRuleGroupForVersion(g rwrulefmt.RuleGroup, version string) error {
+	target, err := parsePrometheusVersion(version)
+	if err != nil {
+		return err
+	}
+
+	var errs multierror.MultiError
+	for _, rule := range g.Rules {
+		expr, err := parser.ParseExpr(rule.Expr.Value)
+		if err != nil {
+			errs.Add(fmt.Errorf("rule %q of rule group %q: %w", getRuleName(rule), g.Name, err))
+			continue
+		}
+
+		for _, feature := range unsupportedFeatures(expr, target) {
+			errs.Add(fmt.Errorf("rule %q of rule group %q: %s is not supported by Prometheus %s", getRuleName(rule), g.Name, feature, target))
+		}
+	}
+
+	return errs.Err()
+}
+
+// unsupportedFeatures returns the description of the features of the expression that are
+// not supported by the target version, along with the version they've been added in.
+func unsupportedFeatures(expr parser.Expr, target prometheusVersion) []string {
+	var features []string
+	seen := map[string]struct{}{}
+	require := func(feature string, since prometheusVersion) {
+		if !target.less(since) {
+			return
+		}
+		feature = fmt.Sprintf("%s (added in %s)", feature, since)
+		if _, ok := seen[feature]; !ok {
+			seen[feature] = struct{}{}
+			features = append(features, feature)
+		}
+	}
+
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.Call:
+			if since, ok := functionVersions[n.Func.Name]; ok {
+				require(fmt.Sprintf("function %s()", n.Func.Name), since)
+			}
+		case *parser.SubqueryExpr:
+			require("subquery", subqueryVersion)
+			if n.Timestamp != nil || n.StartOrEnd != 0 {
+				require("@ modifier", atModifierVersion)
+			}
+			if n.OriginalOffset < 0 {
+				require("negative offset", negativeOffsetVersion)
+			}
+		case *parser.VectorSelector:
+			if n.Timestamp != nil || n.StartOrEnd != 0 {
+				require("@ modifier", atModifierVersion)
+			}
+			if n.OriginalOffset < 0 {
+				require("negative offset", negativeOffsetVersion)
+			}
+		case *parser.AggregateExpr:
+			if n.Op == parser.GROUP {
+				require("aggregation group", groupVersion)
+			}
+		case *parser.BinaryExpr:
+			if n.Op == parser.ATAN2 {
+				require("operator atan2", atan2Version)
+			}
+		}
+		return nil
+	})
+
+	return features
+}
+
+// deprecatedFunction is a PromQL function deprecated in a Prometheus version.
+type deprecatedFunction struct {
+	since       prometheusVersion
+	replacement string
+}
+
+// deprecatedFunctions are the PromQL functions deprecated, renamed or removed in a
+// Prometheus version, along with the function replacing them if any.
+var deprecatedFunctions = map[string]deprecatedFunction{
+	"holt_winters": {since: prometheusVersion{3, 0, 0}, replacement: "double_exponential_smoothing"},
+}
+
+// DeprecationWarning reports a rule using a PromQL function deprecated in the target
+// Prometheus version.
+type DeprecationWarning struct {
+	Group string
+	// Rule is the index of the rule in the rule group.
+	Rule     int
+	RuleName string
+
+	Function    string
+	Since       string
+	Replacement string
+}
+
+func (w DeprecationWarning) String() string {
+	msg := fmt.Sprintf("rule %q of rule group %q: function %s() is deprecated since Prometheus %s", w.RuleName, w.Group, w.Function, w.Since)
+	if w.Replacement != "" {
+		msg += fmt.Sprintf(", use %s() instead", w.Replacement)
+	}
+	return msg
+}
+
+// FindDeprecatedFunctions returns a warning for each function call of the rules of the
+// groups deprecated in the given Prometheus version or before, for example to find the
+// rules to update before upgrading. The expressions which don't parse are skipped, as
+// they're reported by the validation.
+func FindDeprecatedFunctions(groups []rwrulefmt.RuleGroup, version string) ([]DeprecationWarning, error) {
+	target, err := parsePrometheusVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []DeprecationWarning
+	for _, g := range groups {
+		for i, rule := range g.Rules {
+			expr, err := parser.ParseExpr(rule.Expr.Value)
+			if err != nil {
+				continue
+			}
+
+			parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+				call, ok := node.(*parser.Call)
+				if !ok {
+					return nil
+				}
+				deprecation, ok := deprecatedFunctions[call.Func.Name]
+				if !ok || target.less(deprecation.since) {
+					return nil
+				}
+
+				warnings = append(warnings, DeprecationWarning{
+					Group:       g.Name,
+					Rule:        i,
+					RuleName:    getRuleName(rule),
+					Function:    call.Func.Name,
+					Since:       deprecation.since.String(),
+					Replacement: deprecation.replacement,
+				})
+				return nil
+			})
+		}
+	}
+
+	return warnings, nil
+}
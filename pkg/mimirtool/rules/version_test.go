@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rules
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+func TestValidateRuleGroupForVersion(t *testing.T) {
+	tests := map[string]struct {
+		expr         string
+		version      string
+		expectedErr  string
+		expectedErrs []string
+	}{
+		"function supported": {
+			expr:    "last_over_time(up[5m])",
+			version: "2.26.0",
+		},
+		"function not supported yet": {
+			expr:        "last_over_time(up[5m])",
+			version:     "2.25.2",
+			expectedErr: `rule "metric:up" of rule group "group": function last_over_time() (added in 2.26.0) is not supported by Prometheus 2.25.2`,
+		},
+		"version with a v prefix and a pre-release": {
+			expr:    "present_over_time(up[5m])",
+			version: "v2.29.0-rc.1",
+		},
+		"@ modifier not supported yet": {
+			expr:        "up @ 1609746000",
+			version:     "2.24.0",
+			expectedErr: `rule "metric:up" of rule group "group": @ modifier (added in 2.25.0) is not supported by Prometheus 2.24.0`,
+		},
+		"negative offset not supported yet": {
+			expr:        "rate(up[5m] offset -1m)",
+			version:     "2.25.0",
+			expectedErr: `rule "metric:up" of rule group "group": negative offset (added in 2.26.0) is not supported by Prometheus 2.25.0`,
+		},
+		"several features not supported yet": {
+			expr:    "group(sgn(up)) or max_over_time(up[5m:1m] @ 1609746000)",
+			version: "2.6.0",
+			expectedErrs: []string{
+				`aggregation group (added in 2.17.0) is not supported by Prometheus 2.6.0`,
+				`function sgn() (added in 2.26.0) is not supported by Prometheus 2.6.0`,
+				`subquery (added in 2.7.0) is not supported by Prometheus 2.6.0`,
+				`@ modifier (added in 2.25.0) is not supported by Prometheus 2.6.0`,
+			},
+		},
+		"invalid expression": {
+			expr:        "sum(up",
+			version:     "2.30.0",
+			expectedErr: `rule "metric:up" of rule group "group": 1:7: parse error: unclosed left parenthesis`,
+		},
+		"invalid version": {
+			expr:        "up",
+			version:     "latest",
+			expectedErr: `invalid Prometheus version "latest"`,
+		},
+		"empty version": {
+			expr:        "up",
+			version:     "",
+			expectedErr: `invalid Prometheus version ""`,
+		},
+		"version with only a v prefix": {
+			expr:        "up",
+			version:     "v",
+			expectedErr: `invalid Prometheus version "v"`,
+		},
+		"version with an empty patch": {
+			expr:        "up",
+			version:     "2.30.",
+			expectedErr: `invalid Prometheus version "2.30."`,
+		},
+		"version with only a pre-release": {
+			expr:        "up",
+			version:     "-rc1",
+			expectedErr: `invalid Prometheus version "-rc1"`,
+		},
+		"version with only build metadata": {
+			expr:        "up",
+			version:     "+build",
+			expectedErr: `invalid Prometheus version "+build"`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			group := rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: "group", Rules: []rulefmt.RuleNode{
+				{Record: yaml.Node{Value: "metric:up"}, Expr: yaml.Node{Value: testData.expr}},
+			}}}
+
+			err := ValidateRuleGroupForVersion(group, testData.version)
+			if testData.expectedErr == "" && len(testData.expectedErrs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testData.expectedErr)
+			for _, expectedErr := range testData.expectedErrs {
+				assert.Contains(t, err.Error(), expectedErr)
+			}
+		})
+	}
+}
+
+func TestFindDeprecatedFunctions(t *testing.T) {
+	groups := []rwrulefmt.RuleGroup{
+		{RuleGroup: rulefmt.RuleGroup{Name: "group-1", Rules: []rulefmt.RuleNode{
+			{Record: yaml.Node{Value: "metric:rate"}, Expr: yaml.Node{Value: "sum(rate(metric[5m]))"}},
+			{Record: yaml.Node{Value: "metric:smoothed"}, Expr: yaml.Node{Value: "sum(holt_winters(metric[1h], 0.5, 0.5))"}},
+		}}},
+		{RuleGroup: rulefmt.RuleGroup{Name: "group-2", Rules: []rulefmt.RuleNode{
+			{Alert: yaml.Node{Value: "Invalid"}, Expr: yaml.Node{Value: "holt_winters("}},
+		}}},
+	}
+
+	warnings, err := FindDeprecatedFunctions(groups, "3.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, []DeprecationWarning{{
+		Group:       "group-1",
+		Rule:        1,
+		RuleName:    "metric:smoothed",
+		Function:    "holt_winters",
+		Since:       "3.0.0",
+		Replacement: "double_exponential_smoothing",
+	}}, warnings)
+	assert.Equal(t, `rule "metric:smoothed" of rule group "group-1": function holt_winters() is deprecated since Prometheus 3.0.0, use double_exponential_smoothing() instead`, warnings[0].String())
+
+	// The function isn't deprecated yet in a prior version.
+	warnings, err = FindDeprecatedFunctions(groups, "2.32.1")
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	for _, version := range []string{"latest", "", "v", "2.30.", "-rc1"} {
+		_, err = FindDeprecatedFunctions(groups, version)
+		assert.EqualError(t, err, fmt.Sprintf("invalid Prometheus version %q", version))
+	}
+}
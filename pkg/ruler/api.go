@@ -314,6 +314,11 @@ func marshalAndSend(output interface{}, w http.ResponseWriter, logger log.Logger
 }
 
 func respondAccepted(w http.ResponseWriter, logger log.Logger) {
+	// Return a status accepted because the rule has been stored and queued for polling, but is not currently active
+	respondSuccess(w, logger, http.StatusAccepted)
+}
+
+func respondSuccess(w http.ResponseWriter, logger log.Logger, statusCode int) {
 	b, err := json.Marshal(&response{
 		Status: "success",
 	})
@@ -323,9 +328,7 @@ func respondAccepted(w http.ResponseWriter, logger log.Logger) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-
-	// Return a status accepted because the rule has been stored and queued for polling, but is not currently active
-	w.WriteHeader(http.StatusAccepted)
+	w.WriteHeader(statusCode)
 	if n, err := w.Write(b); err != nil {
 		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
 	}
@@ -504,6 +507,12 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// The rule group is only validated, including against the limits, when asked to.
+	if validateOnly, _ := strconv.ParseBool(req.URL.Query().Get("validate_only")); validateOnly {
+		respondSuccess(w, logger, http.StatusOK)
+		return
+	}
+
 	rgProto := rulespb.ToProto(userID, namespace, rg)
 
 	level.Debug(logger).Log("msg", "attempting to store rulegroup", "userID", userID, "group", rgProto.String())
@@ -325,6 +325,37 @@ rules:
 	}
 }
 
+func TestRuler_CreateValidateOnly(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	r := newTestRuler(t, cfg, newMockRuleStore(make(map[string]rulespb.RuleGroupList)))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	router := mux.NewRouter()
+	router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+
+	// An invalid rule group is rejected.
+	req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace?validate_only=true", strings.NewReader("name: test\n"), "user1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "invalid rules config: rule group 'test' has no rules\n", w.Body.String())
+
+	// A valid rule group is accepted, but not stored.
+	input := "name: test\nrules:\n- record: up_rule\n  expr: up{}\n"
+	req = requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace?validate_only=true", strings.NewReader(input), "user1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"success","data":null,"errorType":"","error":""}`, w.Body.String())
+
+	rgs, err := r.store.ListRuleGroupsForUserAndNamespace(context.Background(), "user1", "")
+	require.NoError(t, err)
+	require.Empty(t, rgs)
+}
+
 func TestRuler_DeleteNamespace(t *testing.T) {
 	cfg := defaultRulerConfig(t)
 